@@ -0,0 +1,154 @@
+// Package apperror defines the domain error model shared by services,
+// controllers and middleware. Each error carries a Kind, which the error
+// middleware maps to an HTTP status, and a stable machine-readable Code that
+// clients can branch on.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kind classifies an error independently of the transport
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindUnsupportedMediaType
+	KindPreconditionFailed
+	KindPayloadTooLarge
+	KindTooManyRequests
+)
+
+// Stable error codes shared across endpoints
+const (
+	CodeInternal         = "internal_error"
+	CodeInvalidRequest   = "invalid_request"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodePrecondition     = "precondition_failed"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeTooManyRequests  = "too_many_requests"
+)
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+type Error struct {
+	Kind   Kind
+	Code   string
+	Detail string
+	Fields []FieldError
+	// RetryAfter tells clients how long to wait before trying again. It is
+	// sent as the Retry-After header when set.
+	RetryAfter time.Duration
+	// Err is the underlying cause. It is logged but never shown to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return e.Code + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same code, so that a sentinel such as
+// services.ErrUserNotFound also matches copies wrapping a cause
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && t.Kind == e.Kind
+}
+
+// Wrap returns a copy of e carrying err as its cause
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithFields returns a copy of e reporting the given fields
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	withFields := *e
+	withFields.Fields = fields
+	return &withFields
+}
+
+// WithRetryAfter returns a copy of e asking clients to wait d before retrying
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	withRetry := *e
+	withRetry.RetryAfter = d
+	return &withRetry
+}
+
+func New(kind Kind, code, detail string) *Error {
+	return &Error{Kind: kind, Code: code, Detail: detail}
+}
+
+func BadRequest(code, detail string) *Error {
+	return New(KindBadRequest, code, detail)
+}
+
+func Unauthorized(code, detail string) *Error {
+	return New(KindUnauthorized, code, detail)
+}
+
+func Forbidden(code, detail string) *Error {
+	return New(KindForbidden, code, detail)
+}
+
+func NotFound(code, detail string) *Error {
+	return New(KindNotFound, code, detail)
+}
+
+func Conflict(code, detail string) *Error {
+	return New(KindConflict, code, detail)
+}
+
+func PreconditionFailed(code, detail string) *Error {
+	return New(KindPreconditionFailed, code, detail)
+}
+
+func TooManyRequests(code, detail string) *Error {
+	return New(KindTooManyRequests, code, detail)
+}
+
+// Validation reports one or more rejected fields
+func Validation(detail string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: CodeValidationFailed, Detail: detail, Fields: fields}
+}
+
+// Internal hides err behind a generic message
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: CodeInternal, Detail: "An unexpected error occurred", Err: err}
+}
+
+// As extracts the *Error from err's chain. Errors that are not part of the
+// domain model are reported as internal errors.
+func As(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err)
+}
@@ -0,0 +1,73 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// unknownFieldPrefix starts the error encoding/json returns for unknown
+// fields when decoding with DisallowUnknownFields
+const unknownFieldPrefix = "json: unknown field "
+
+// FromBinding classifies err, turning request binding and validation
+// failures into validation or bad request errors without exposing parser
+// internals. Other errors are handled as by As.
+func FromBinding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, len(validationErrs))
+		for i, fe := range validationErrs {
+			fields[i] = FieldError{
+				Field:   fe.Field(),
+				Code:    fe.Tag(),
+				Message: validationMessage(fe),
+			}
+		}
+		return Validation("The request contains invalid fields", fields...)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return Validation("The request contains invalid fields", FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be a " + typeErr.Type.String(),
+		})
+	case strings.HasPrefix(err.Error(), unknownFieldPrefix):
+		// encoding/json has no typed error for DisallowUnknownFields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`)
+		return Validation("The request contains invalid fields", FieldError{
+			Field:   field,
+			Code:    "unknown_field",
+			Message: "is not a recognized field",
+		})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest(CodeInvalidRequest, "The request body is not valid JSON")
+	}
+
+	return As(err)
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	default:
+		return "is invalid"
+	}
+}
@@ -1,46 +1,147 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/canhbk/golang-gin-starter-kit/config"
 	"github.com/canhbk/golang-gin-starter-kit/database/migration"
 	"github.com/canhbk/golang-gin-starter-kit/database/seeder"
-	"github.com/joho/godotenv"
+	"github.com/canhbk/golang-gin-starter-kit/password"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/services"
 )
 
-func init() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
-	}
-}
-
 func main() {
 	// Parse command line flags
-	migrate := flag.Bool("migrate", false, "Run database migrations")
-	rollback := flag.Bool("rollback", false, "Rollback database migrations")
+	migrate := flag.Bool("migrate", false, "Apply all pending migrations")
+	rollback := flag.Bool("rollback", false, "Revert all applied migrations")
 	seed := flag.Bool("seed", false, "Seed database with initial data")
 	refresh := flag.Bool("refresh", false, "Rollback, migrate, and seed database")
+	status := flag.Bool("status", false, "Show applied and pending migrations")
+	up := flag.Int("up", 0, "Apply the next N pending migrations")
+	down := flag.Int("down", 0, "Revert the last N applied migrations")
+	create := flag.String("create", "", "Create a new migration file with the given name")
+	dir := flag.String("dir", "database/migration", "Directory for new migration files")
+	purgeDeleted := flag.Int("purge-deleted", 0, "Permanently remove users soft-deleted more than N days ago")
+	purgeAttempts := flag.Bool("purge-login-attempts", false, "Remove failed login counters older than the lockout window")
 	flag.Parse()
 
-	// Initialize database connection
-	config.InitializeDB()
+	// Creating a migration only writes a file, so no connection is needed
+	if *create != "" {
+		path, err := migration.Create(*dir, *create)
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		log.Printf("Created %s", path)
+		return
+	}
+
+	// Load configuration and initialize database connection
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	config.InitializeLogger(cfg.Log)
+	config.InitializeDB(cfg.Database)
+
+	hasher, err := password.NewHasher(cfg.Security.Password)
+	if err != nil {
+		log.Fatalf("Failed to initialize password hasher: %v", err)
+	}
 
 	// Execute commands based on flags
 	if *refresh {
-		migration.Rollback()
-		migration.AutoMigrate()
-		seeder.RunSeeders()
+		run(migration.Down(0))
+		run(migration.Up(0))
+		seeder.RunSeeders(hasher)
 	} else {
 		if *rollback {
-			migration.Rollback()
+			run(migration.Down(0))
+		}
+		if *down > 0 {
+			run(migration.Down(*down))
 		}
 		if *migrate {
-			migration.AutoMigrate()
+			run(migration.Up(0))
+		}
+		if *up > 0 {
+			run(migration.Up(*up))
 		}
 		if *seed {
-			seeder.RunSeeders()
+			seeder.RunSeeders(hasher)
+		}
+	}
+
+	if *purgeDeleted > 0 {
+		purgeDeletedUsers(cfg.Security.Password, *purgeDeleted)
+	}
+
+	if *purgeAttempts {
+		purgeLoginAttempts(cfg.Security.Lockout.Window)
+	}
+
+	if *status {
+		printStatus()
+	}
+}
+
+// purgeDeletedUsers enforces the data-retention policy for deleted accounts
+func purgeDeletedUsers(passwordConfig config.PasswordConfig, days int) {
+	userRepository := repositories.NewUserRepository(config.DB)
+	passwords, err := services.NewPasswordService(userRepository,
+		repositories.NewPasswordHistoryRepository(config.DB), passwordConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize password service: %v", err)
+	}
+	users := services.NewUserService(userRepository, passwords, nil)
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	purged, err := users.PurgeDeletedBefore(context.Background(), cutoff)
+	if err != nil {
+		log.Fatalf("Purge failed after removing %d users: %v", purged, err)
+	}
+	log.Printf("Purged %d users deleted before %s", purged, cutoff.Format(time.RFC3339))
+}
+
+// purgeLoginAttempts removes the counters that no longer affect logins, such
+// as those left behind by mistyped or made-up usernames
+func purgeLoginAttempts(window time.Duration) {
+	attempts := repositories.NewLoginAttemptRepository(config.DB)
+	now := time.Now()
+
+	purged, err := attempts.DeleteStale(context.Background(), now, now.Add(-window))
+	if err != nil {
+		log.Fatalf("Failed to purge login attempts: %v", err)
+	}
+	log.Printf("Purged %d login attempt counters", purged)
+}
+
+func run(err error) {
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
+
+func printStatus() {
+	statuses, err := migration.Statuses()
+	if err != nil {
+		log.Fatalf("Failed to read migration status: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, s := range statuses {
+		state := "pending"
+		if s.AppliedAt != nil {
+			state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Version, s.Name, state)
 	}
+	w.Flush()
 }
@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+type APIKeyConfig struct {
+	// DefaultTTL is the lifetime of keys created without an expiry
+	DefaultTTL time.Duration `yaml:"default_ttl" env:"API_KEY_DEFAULT_TTL"`
+	// MaxTTL bounds how far in the future a key may expire
+	MaxTTL time.Duration `yaml:"max_ttl" env:"API_KEY_MAX_TTL"`
+	// LastUsedInterval limits how often a key's last-used time is written,
+	// so that busy keys do not update their row on every request
+	LastUsedInterval time.Duration `yaml:"last_used_interval" env:"API_KEY_LAST_USED_INTERVAL"`
+}
+
+func (c APIKeyConfig) validate() []string {
+	var problems []string
+	if c.DefaultTTL <= 0 {
+		problems = append(problems, "API_KEY_DEFAULT_TTL: must be greater than zero")
+	}
+	if c.MaxTTL < c.DefaultTTL {
+		problems = append(problems, "API_KEY_MAX_TTL: must be at least API_KEY_DEFAULT_TTL")
+	}
+	if c.LastUsedInterval < 0 {
+		problems = append(problems, "API_KEY_LAST_USED_INTERVAL: must not be negative")
+	}
+	return problems
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when CONFIG_FILE is not set and the file exists
+const DefaultConfigFile = "config.yaml"
+
+// Config is the complete application configuration
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DBConfig       `yaml:"database"`
+	Log      LogConfig      `yaml:"log"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Mail     MailConfig     `yaml:"mail"`
+	Security SecurityConfig `yaml:"security"`
+}
+
+type SecurityConfig struct {
+	JWT               JWTConfig               `yaml:"jwt"`
+	Password          PasswordConfig          `yaml:"password"`
+	PasswordReset     PasswordResetConfig     `yaml:"password_reset"`
+	EmailVerification EmailVerificationConfig `yaml:"email_verification"`
+	MFA               MFAConfig               `yaml:"mfa"`
+	Lockout           LockoutConfig           `yaml:"lockout"`
+	APIKeys           APIKeyConfig            `yaml:"api_keys"`
+}
+
+// ValidationError lists every invalid configuration field
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Default returns the configuration used when nothing else is provided
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:               "8080",
+			Mode:               "debug",
+			ReadTimeout:        15 * time.Second,
+			ReadHeaderTimeout:  5 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownTimeout:    30 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+		},
+		Database: DBConfig{
+			Driver:  DriverMySQL,
+			Host:    "localhost",
+			User:    "root",
+			DBName:  "example",
+			SSLMode: "disable",
+			Pool: PoolConfig{
+				MaxOpenConns:    100,
+				MaxIdleConns:    10,
+				ConnMaxLifetime: time.Hour,
+			},
+			SlowQueryThreshold: time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Path:    "/metrics",
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			ServiceName: "golang-gin-starter-kit",
+			SampleRatio: 1,
+		},
+		Mail: MailConfig{
+			Driver: MailDriverLog,
+			From:   "no-reply@example.com",
+			Dir:    "tmp/mail",
+		},
+		Security: SecurityConfig{
+			JWT: JWTConfig{
+				Issuer:          "golang-gin-starter-kit",
+				AccessTokenTTL:  15 * time.Minute,
+				RefreshTokenTTL: 7 * 24 * time.Hour,
+			},
+			Password: PasswordConfig{
+				Algorithm:         PasswordAlgorithmBcrypt,
+				BcryptCost:        12,
+				Argon2Memory:      64 * 1024,
+				Argon2Iterations:  3,
+				Argon2Parallelism: 2,
+				MinLength:         8,
+				MaxLength:         72,
+				History:           3,
+			},
+			PasswordReset: PasswordResetConfig{
+				TokenTTL:    time.Hour,
+				URL:         "http://localhost:8080/reset-password",
+				MaxPerEmail: 3,
+				MaxPerIP:    20,
+			},
+			EmailVerification: EmailVerificationConfig{
+				TokenTTL:       24 * time.Hour,
+				URL:            "http://localhost:8080/api/v1/auth/verify",
+				ResendInterval: time.Minute,
+			},
+			MFA: MFAConfig{
+				Issuer:        "golang-gin-starter-kit",
+				ChallengeTTL:  5 * time.Minute,
+				RequiredRoles: "admin",
+			},
+			Lockout: LockoutConfig{
+				Store:          LockoutStoreDatabase,
+				Window:         15 * time.Minute,
+				Duration:       15 * time.Minute,
+				BaseDelay:      time.Second,
+				MaxDelay:       30 * time.Second,
+				FreeAttempts:   3,
+				MaxAttempts:    10,
+				IPFreeAttempts: 20,
+				IPMaxAttempts:  100,
+			},
+			APIKeys: APIKeyConfig{
+				DefaultTTL:       90 * 24 * time.Hour,
+				MaxTTL:           365 * 24 * time.Hour,
+				LastUsedInterval: time.Minute,
+			},
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, an optional YAML file, the .env file and the environment.
+// The result is validated and every problem is reported at once.
+func Load() (*Config, error) {
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+	lookup := func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := dotenv[key]
+		return value, ok
+	}
+
+	cfg := Default()
+
+	path, explicit := lookup("CONFIG_FILE")
+	if !explicit {
+		path = DefaultConfigFile
+	}
+	if err := cfg.loadFile(path, explicit); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	problems = append(problems, applyEnv(cfg, lookup)...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// loadFile overlays the YAML file at path. A missing file is only an error
+// when it was requested explicitly through CONFIG_FILE.
+func (c *Config) loadFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+	problems = append(problems, c.Server.validate()...)
+	problems = append(problems, c.Database.validate()...)
+	problems = append(problems, c.Log.validate()...)
+	problems = append(problems, c.Metrics.validate()...)
+	problems = append(problems, c.Tracing.validate()...)
+	problems = append(problems, c.Mail.validate()...)
+	problems = append(problems, c.Security.JWT.validate(c.Server.Mode)...)
+	problems = append(problems, c.Security.Password.validate()...)
+	problems = append(problems, c.Security.PasswordReset.validate()...)
+	problems = append(problems, c.Security.EmailVerification.validate()...)
+	problems = append(problems, c.Security.MFA.validate()...)
+	problems = append(problems, c.Security.Lockout.validate()...)
+	problems = append(problems, c.Security.APIKeys.validate()...)
+	return problems
+}
@@ -3,53 +3,57 @@ package config
 import (
 	"fmt"
 	"log"
-	"os"
+	"log/slog"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+)
+
+// Supported values for DB_DRIVER
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
 type DBConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Driver   string     `yaml:"driver" env:"DB_DRIVER"`
+	Host     string     `yaml:"host" env:"DB_HOST"`
+	Port     string     `yaml:"port" env:"DB_PORT"`
+	User     string     `yaml:"user" env:"DB_USER"`
+	Password string     `yaml:"password" env:"DB_PASSWORD"`
+	DBName   string     `yaml:"name" env:"DB_NAME"`
+	SSLMode  string     `yaml:"sslmode" env:"DB_SSLMODE"`
+	Pool     PoolConfig `yaml:"pool"`
+	// SlowQueryThreshold is the duration above which queries are logged as slow
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD"`
+}
+
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
 }
 
 var DB *gorm.DB
 
-func InitializeDB() {
-	config := DBConfig{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "3306"),
-		User:     getEnv("DB_USER", "root"),
-		Password: getEnv("DB_PASSWORD", ""),
-		DBName:   getEnv("DB_NAME", "example"),
-	}
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-	)
+func InitializeDB(config DBConfig) {
+	dialector, err := config.Dialector()
+	if err != nil {
+		log.Fatalf("Failed to configure database: %v", err)
+	}
 
-	// Configure GORM logger
-	gormLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Info,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  true,
-		},
-	)
+	// Send GORM logs through the structured logger, tagged with the request ID
+	gormLogger := logging.NewGormLogger(slog.Default(), config.SlowQueryThreshold)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: gormLogger,
 	})
 	if err != nil {
@@ -62,17 +66,134 @@ func InitializeDB() {
 		log.Fatalf("Failed to get database instance: %v", err)
 	}
 
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxIdleConns(config.Pool.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(config.Pool.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(config.Pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.Pool.ConnMaxIdleTime)
 
 	DB = db
-	log.Println("Database connection established successfully")
+	log.Printf("Database connection established successfully (driver: %s)", config.Driver)
+}
+
+// CloseDB closes the connection pool behind DB
+func CloseDB() {
+	if DB == nil {
+		return
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		log.Printf("Failed to get database instance: %v", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("Failed to close database connection: %v", err)
+		return
+	}
+	log.Println("Database connection closed")
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// Dialector returns the GORM dialector for the configured driver
+func (c DBConfig) Dialector() (gorm.Dialector, error) {
+	switch c.Driver {
+	case DriverMySQL:
+		return mysql.Open(c.mysqlDSN()), nil
+	case DriverPostgres:
+		return postgres.Open(c.postgresDSN()), nil
+	case DriverSQLite:
+		return sqlite.Open(c.sqliteDSN()), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (expected %s, %s or %s)",
+			c.Driver, DriverMySQL, DriverPostgres, DriverSQLite)
+	}
+}
+
+func (c DBConfig) mysqlDSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		c.User,
+		c.Password,
+		c.Host,
+		c.port(),
+		c.DBName,
+	)
+}
+
+func (c DBConfig) postgresDSN() string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   c.Host + ":" + c.port(),
+		Path:   c.DBName,
+	}
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	dsn.RawQuery = query.Encode()
+	return dsn.String()
+}
+
+// sqliteDSN treats DB_NAME as the database file path. The special name
+// ":memory:" opens an in-memory database shared by all pool connections.
+func (c DBConfig) sqliteDSN() string {
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if c.DBName == ":memory:" {
+		return "file::memory:?cache=shared&" + pragmas
+	}
+	return "file:" + c.DBName + "?" + pragmas
+}
+
+// port returns the configured port or the driver's default
+func (c DBConfig) port() string {
+	if c.Port != "" {
+		return c.Port
+	}
+	if c.Driver == DriverPostgres {
+		return "5432"
+	}
+	return "3306"
+}
+
+func (c DBConfig) validate() []string {
+	var problems []string
+	switch c.Driver {
+	case DriverMySQL, DriverPostgres:
+		if c.Host == "" {
+			problems = append(problems, "DB_HOST: must be set")
+		}
+		if c.Port != "" {
+			if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+				problems = append(problems, fmt.Sprintf("DB_PORT: must be between 1 and 65535, got %q", c.Port))
+			}
+		}
+	case DriverSQLite:
+	default:
+		problems = append(problems, fmt.Sprintf("DB_DRIVER: must be %s, %s or %s, got %q",
+			DriverMySQL, DriverPostgres, DriverSQLite, c.Driver))
+	}
+	if c.DBName == "" {
+		problems = append(problems, "DB_NAME: must be set")
+	}
+	if c.Driver == DriverPostgres {
+		switch c.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			problems = append(problems, fmt.Sprintf("DB_SSLMODE: unsupported value %q", c.SSLMode))
+		}
+	}
+
+	if c.Pool.MaxOpenConns < 0 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS: must not be negative")
+	}
+	if c.Pool.MaxIdleConns < 0 {
+		problems = append(problems, "DB_MAX_IDLE_CONNS: must not be negative")
+	}
+	if c.Pool.MaxOpenConns > 0 && c.Pool.MaxIdleConns > c.Pool.MaxOpenConns {
+		problems = append(problems, "DB_MAX_IDLE_CONNS: must not exceed DB_MAX_OPEN_CONNS")
+	}
+	if c.Pool.ConnMaxLifetime < 0 {
+		problems = append(problems, "DB_CONN_MAX_LIFETIME: must not be negative")
+	}
+	if c.Pool.ConnMaxIdleTime < 0 {
+		problems = append(problems, "DB_CONN_MAX_IDLE_TIME: must not be negative")
 	}
-	return defaultValue
+	return problems
 }
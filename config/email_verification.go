@@ -0,0 +1,31 @@
+package config
+
+import (
+	"net/url"
+	"time"
+)
+
+type EmailVerificationConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env:"EMAIL_VERIFICATION_TTL"`
+	// URL is where verification links point. It defaults to the API's own
+	// GET /api/v1/auth/verify endpoint; the token is appended as the token
+	// query parameter.
+	URL string `yaml:"url" env:"EMAIL_VERIFICATION_URL"`
+	// ResendInterval is the minimum time between two verification emails
+	// to the same user
+	ResendInterval time.Duration `yaml:"resend_interval" env:"EMAIL_VERIFICATION_RESEND_INTERVAL"`
+}
+
+func (c EmailVerificationConfig) validate() []string {
+	var problems []string
+	if c.TokenTTL <= 0 {
+		problems = append(problems, "EMAIL_VERIFICATION_TTL: must be greater than zero")
+	}
+	if u, err := url.Parse(c.URL); err != nil || !u.IsAbs() {
+		problems = append(problems, "EMAIL_VERIFICATION_URL: must be an absolute URL")
+	}
+	if c.ResendInterval < 0 {
+		problems = append(problems, "EMAIL_VERIFICATION_RESEND_INTERVAL: must not be negative")
+	}
+	return problems
+}
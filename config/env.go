@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv sets every field tagged with `env` whose variable is present,
+// walking nested structs. It returns one problem per value that cannot be parsed.
+func applyEnv(target interface{}, lookup func(string) (string, bool)) []string {
+	return applyEnvValue(reflect.ValueOf(target).Elem(), lookup)
+}
+
+func applyEnvValue(v reflect.Value, lookup func(string) (string, bool)) []string {
+	var problems []string
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		key := t.Field(i).Tag.Get("env")
+
+		if key == "" {
+			if field.Kind() == reflect.Struct && field.Type() != durationType {
+				problems = append(problems, applyEnvValue(field, lookup)...)
+			}
+			continue
+		}
+
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	return problems
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// minReleaseSecretLength is the shortest JWT secret accepted in release mode
+const minReleaseSecretLength = 32
+
+type JWTConfig struct {
+	Secret          string        `yaml:"secret" env:"JWT_SECRET"`
+	Issuer          string        `yaml:"issuer" env:"JWT_ISSUER"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" env:"JWT_EXPIRATION"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"JWT_REFRESH_EXPIRATION"`
+}
+
+var JWT JWTConfig
+
+func InitializeJWT(config JWTConfig) {
+	JWT = config
+}
+
+func (c JWTConfig) validate(mode string) []string {
+	var problems []string
+	switch {
+	case c.Secret == "":
+		problems = append(problems, "JWT_SECRET: must be set")
+	case mode == "release" && len(c.Secret) < minReleaseSecretLength:
+		problems = append(problems, fmt.Sprintf("JWT_SECRET: must be at least %d characters in release mode", minReleaseSecretLength))
+	}
+	if c.Issuer == "" {
+		problems = append(problems, "JWT_ISSUER: must be set")
+	}
+	if c.AccessTokenTTL <= 0 {
+		problems = append(problems, "JWT_EXPIRATION: must be greater than zero")
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		problems = append(problems, "JWT_REFRESH_EXPIRATION: must be longer than JWT_EXPIRATION")
+	}
+	return problems
+}
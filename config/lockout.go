@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	LockoutStoreMemory   = "memory"
+	LockoutStoreDatabase = "database"
+)
+
+// LockoutConfig limits password guessing. Failed logins are counted per
+// account and per client IP. Past the free attempts each further failure
+// doubles the wait before the next try, and reaching the maximum locks the
+// account or IP out for Duration.
+type LockoutConfig struct {
+	// Store keeps the counters: memory is per process, database is shared
+	// by every replica
+	Store string `yaml:"store" env:"LOCKOUT_STORE"`
+	// Window is how long failures are remembered; a failure more than
+	// Window after the previous one starts the count over
+	Window   time.Duration `yaml:"window" env:"LOCKOUT_WINDOW"`
+	Duration time.Duration `yaml:"duration" env:"LOCKOUT_DURATION"`
+	// BaseDelay is the wait after the first failure past the free attempts;
+	// every further failure doubles it up to MaxDelay
+	BaseDelay      time.Duration `yaml:"base_delay" env:"LOCKOUT_BASE_DELAY"`
+	MaxDelay       time.Duration `yaml:"max_delay" env:"LOCKOUT_MAX_DELAY"`
+	FreeAttempts   int           `yaml:"free_attempts" env:"LOCKOUT_FREE_ATTEMPTS"`
+	MaxAttempts    int           `yaml:"max_attempts" env:"LOCKOUT_MAX_ATTEMPTS"`
+	IPFreeAttempts int           `yaml:"ip_free_attempts" env:"LOCKOUT_IP_FREE_ATTEMPTS"`
+	IPMaxAttempts  int           `yaml:"ip_max_attempts" env:"LOCKOUT_IP_MAX_ATTEMPTS"`
+}
+
+func (c LockoutConfig) validate() []string {
+	var problems []string
+	switch c.Store {
+	case LockoutStoreMemory, LockoutStoreDatabase:
+	default:
+		problems = append(problems, fmt.Sprintf("LOCKOUT_STORE: must be memory or database, got %q", c.Store))
+	}
+
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"LOCKOUT_WINDOW", c.Window},
+		{"LOCKOUT_DURATION", c.Duration},
+		{"LOCKOUT_BASE_DELAY", c.BaseDelay},
+		{"LOCKOUT_MAX_DELAY", c.MaxDelay},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be greater than zero", d.key))
+		}
+	}
+	if c.MaxDelay < c.BaseDelay {
+		problems = append(problems, "LOCKOUT_MAX_DELAY: must not be shorter than LOCKOUT_BASE_DELAY")
+	}
+
+	if c.FreeAttempts < 0 {
+		problems = append(problems, "LOCKOUT_FREE_ATTEMPTS: must not be negative")
+	}
+	if c.MaxAttempts <= c.FreeAttempts {
+		problems = append(problems, "LOCKOUT_MAX_ATTEMPTS: must be greater than LOCKOUT_FREE_ATTEMPTS")
+	}
+	if c.IPFreeAttempts < 0 {
+		problems = append(problems, "LOCKOUT_IP_FREE_ATTEMPTS: must not be negative")
+	}
+	if c.IPMaxAttempts <= c.IPFreeAttempts {
+		problems = append(problems, "LOCKOUT_IP_MAX_ATTEMPTS: must be greater than LOCKOUT_IP_FREE_ATTEMPTS")
+	}
+	return problems
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+)
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+// InitializeLogger installs the structured logger as the process-wide
+// default. Output of the standard log package is routed through it as well.
+func InitializeLogger(config LogConfig) {
+	// The configuration has been validated, so this cannot fail
+	logger, _ := logging.New(os.Stdout, config.Level, config.Format)
+	slog.SetDefault(logger)
+}
+
+func (c LogConfig) validate() []string {
+	var problems []string
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL: must be debug, info, warn or error, got %q", c.Level))
+	}
+	if c.Format != logging.FormatJSON && c.Format != logging.FormatText {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT: must be %s or %s, got %q", logging.FormatJSON, logging.FormatText, c.Format))
+	}
+	return problems
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Supported values for MAIL_DRIVER
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+)
+
+// MailConfig selects how outgoing mail is delivered. Both drivers are
+// stand-ins for a real provider: log records each message's recipient and
+// subject in the application log and file stores each one as an .eml file
+// in Dir.
+type MailConfig struct {
+	Driver string `yaml:"driver" env:"MAIL_DRIVER"`
+	From   string `yaml:"from" env:"MAIL_FROM"`
+	Dir    string `yaml:"dir" env:"MAIL_DIR"`
+}
+
+func (c MailConfig) validate() []string {
+	var problems []string
+	switch c.Driver {
+	case MailDriverLog:
+	case MailDriverFile:
+		if c.Dir == "" {
+			problems = append(problems, "MAIL_DIR: must be set when MAIL_DRIVER is file")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("MAIL_DRIVER: must be %s or %s, got %q",
+			MailDriverLog, MailDriverFile, c.Driver))
+	}
+	if c.From == "" {
+		problems = append(problems, "MAIL_FROM: must be set")
+	}
+	return problems
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+	Path    string `yaml:"path" env:"METRICS_PATH"`
+}
+
+func (c MetricsConfig) validate() []string {
+	if c.Enabled && !strings.HasPrefix(c.Path, "/") {
+		return []string{fmt.Sprintf("METRICS_PATH: must start with /, got %q", c.Path)}
+	}
+	return nil
+}
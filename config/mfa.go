@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mfaKeyLength is the size of the AES-256 key protecting TOTP secrets
+const mfaKeyLength = 32
+
+type MFAConfig struct {
+	// EncryptionKey encrypts TOTP secrets at rest. It is 32 random bytes,
+	// base64 encoded, for example the output of `openssl rand -base64 32`.
+	EncryptionKey string `yaml:"encryption_key" env:"MFA_ENCRYPTION_KEY"`
+	// Issuer names the service in authenticator apps
+	Issuer string `yaml:"issuer" env:"MFA_ISSUER"`
+	// ChallengeTTL bounds the time between the password and the code step
+	// of a login
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env:"MFA_CHALLENGE_TTL"`
+	// RequiredRoles is a comma-separated list of roles whose members hold
+	// none of their permissions until they enable MFA. Empty turns the
+	// requirement off.
+	RequiredRoles string `yaml:"required_roles" env:"MFA_REQUIRED_ROLES"`
+}
+
+// RequiredRoleList splits RequiredRoles into its entries
+func (c MFAConfig) RequiredRoleList() []string {
+	var roles []string
+	for _, role := range strings.Split(c.RequiredRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Key returns the decoded encryption key
+func (c MFAConfig) Key() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("must be base64 encoded")
+	}
+	if len(key) != mfaKeyLength {
+		return nil, fmt.Errorf("must decode to %d bytes, got %d", mfaKeyLength, len(key))
+	}
+	return key, nil
+}
+
+func (c MFAConfig) validate() []string {
+	var problems []string
+	if c.EncryptionKey == "" {
+		problems = append(problems, "MFA_ENCRYPTION_KEY: must be set")
+	} else if _, err := c.Key(); err != nil {
+		problems = append(problems, "MFA_ENCRYPTION_KEY: "+err.Error())
+	}
+	if c.Issuer == "" {
+		problems = append(problems, "MFA_ISSUER: must be set")
+	}
+	if c.ChallengeTTL <= 0 {
+		problems = append(problems, "MFA_CHALLENGE_TTL: must be greater than zero")
+	}
+	return problems
+}
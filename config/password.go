@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Supported values for PASSWORD_ALGORITHM
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+// Limits of the hashing parameters
+const (
+	bcryptMinCost       = 4
+	bcryptMaxCost       = 31
+	bcryptMaxPassword   = 72
+	argon2MaxParallel   = 255
+	argon2MinMemoryUnit = 8
+)
+
+// PasswordConfig selects how passwords are hashed and which passwords are
+// accepted. Hashes made with another algorithm or weaker parameters still
+// verify, and are replaced at the next successful login.
+type PasswordConfig struct {
+	Algorithm  string `yaml:"algorithm" env:"PASSWORD_ALGORITHM"`
+	BcryptCost int    `yaml:"bcrypt_cost" env:"PASSWORD_BCRYPT_COST"`
+	// Argon2Memory is in KiB
+	Argon2Memory      int `yaml:"argon2_memory" env:"PASSWORD_ARGON2_MEMORY"`
+	Argon2Iterations  int `yaml:"argon2_iterations" env:"PASSWORD_ARGON2_ITERATIONS"`
+	Argon2Parallelism int `yaml:"argon2_parallelism" env:"PASSWORD_ARGON2_PARALLELISM"`
+
+	// MinLength counts characters; MaxLength counts bytes, since bcrypt
+	// ignores everything past 72 bytes
+	MinLength     int  `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MaxLength     int  `yaml:"max_length" env:"PASSWORD_MAX_LENGTH"`
+	RequireUpper  bool `yaml:"require_upper" env:"PASSWORD_REQUIRE_UPPER"`
+	RequireLower  bool `yaml:"require_lower" env:"PASSWORD_REQUIRE_LOWER"`
+	RequireDigit  bool `yaml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol bool `yaml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	// BannedFile lists further rejected passwords, one per line, on top of
+	// the built-in list of common passwords
+	BannedFile string `yaml:"banned_file" env:"PASSWORD_BANNED_FILE"`
+	// History is how many recent passwords, the current one included, a
+	// user may not choose again. Zero allows any.
+	History int `yaml:"history" env:"PASSWORD_HISTORY"`
+}
+
+func (c PasswordConfig) validate() []string {
+	var problems []string
+	switch c.Algorithm {
+	case PasswordAlgorithmBcrypt:
+		if c.BcryptCost < bcryptMinCost || c.BcryptCost > bcryptMaxCost {
+			problems = append(problems, fmt.Sprintf("PASSWORD_BCRYPT_COST: must be between %d and %d, got %d",
+				bcryptMinCost, bcryptMaxCost, c.BcryptCost))
+		}
+		if c.MaxLength > bcryptMaxPassword {
+			problems = append(problems, fmt.Sprintf("PASSWORD_MAX_LENGTH: must be at most %d with bcrypt", bcryptMaxPassword))
+		}
+	case PasswordAlgorithmArgon2id:
+		if c.Argon2Iterations < 1 {
+			problems = append(problems, "PASSWORD_ARGON2_ITERATIONS: must be at least 1")
+		}
+		if c.Argon2Parallelism < 1 || c.Argon2Parallelism > argon2MaxParallel {
+			problems = append(problems, fmt.Sprintf("PASSWORD_ARGON2_PARALLELISM: must be between 1 and %d", argon2MaxParallel))
+		}
+		if c.Argon2Memory < argon2MinMemoryUnit*c.Argon2Parallelism {
+			problems = append(problems, "PASSWORD_ARGON2_MEMORY: must be at least 8 KiB per thread of PASSWORD_ARGON2_PARALLELISM")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("PASSWORD_ALGORITHM: must be %s or %s, got %q",
+			PasswordAlgorithmBcrypt, PasswordAlgorithmArgon2id, c.Algorithm))
+	}
+
+	if c.MinLength < 1 {
+		problems = append(problems, "PASSWORD_MIN_LENGTH: must be at least 1")
+	}
+	if c.MaxLength < c.MinLength {
+		problems = append(problems, "PASSWORD_MAX_LENGTH: must not be less than PASSWORD_MIN_LENGTH")
+	}
+	if c.History < 0 {
+		problems = append(problems, "PASSWORD_HISTORY: must not be negative")
+	}
+	return problems
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"net/url"
+	"time"
+)
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env:"PASSWORD_RESET_TTL"`
+	// URL is the page of the client application that accepts the new
+	// password. The reset token is appended as the token query parameter.
+	URL string `yaml:"url" env:"PASSWORD_RESET_URL"`
+	// MaxPerEmail and MaxPerIP limit reset requests per address and per
+	// client IP. Requests are counted like failed logins and forgotten
+	// after LOCKOUT_WINDOW without a new one.
+	MaxPerEmail int `yaml:"max_per_email" env:"PASSWORD_RESET_MAX_PER_EMAIL"`
+	MaxPerIP    int `yaml:"max_per_ip" env:"PASSWORD_RESET_MAX_PER_IP"`
+}
+
+func (c PasswordResetConfig) validate() []string {
+	var problems []string
+	if c.TokenTTL <= 0 {
+		problems = append(problems, "PASSWORD_RESET_TTL: must be greater than zero")
+	}
+	if u, err := url.Parse(c.URL); err != nil || !u.IsAbs() {
+		problems = append(problems, "PASSWORD_RESET_URL: must be an absolute URL")
+	}
+	if c.MaxPerEmail <= 0 {
+		problems = append(problems, "PASSWORD_RESET_MAX_PER_EMAIL: must be greater than zero")
+	}
+	if c.MaxPerIP <= 0 {
+		problems = append(problems, "PASSWORD_RESET_MAX_PER_IP: must be greater than zero")
+	}
+	return problems
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ServerConfig struct {
+	Port              string        `yaml:"port" env:"PORT"`
+	Mode              string        `yaml:"mode" env:"GIN_MODE"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	// HealthCheckTimeout bounds each dependency probe of the readiness endpoint
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// TrustedProxies is a comma-separated list of the IPs or CIDR ranges of
+	// reverse proxies whose X-Forwarded-For header is believed. When empty
+	// the client IP is always the peer address.
+	TrustedProxies string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// TrustedProxyList splits TrustedProxies into its entries
+func (c ServerConfig) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(c.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+func (c ServerConfig) validate() []string {
+	var problems []string
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("PORT: must be between 1 and 65535, got %q", c.Port))
+	}
+	switch c.Mode {
+	case "debug", "release", "test":
+	default:
+		problems = append(problems, fmt.Sprintf("GIN_MODE: must be debug, release or test, got %q", c.Mode))
+	}
+
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", c.ReadTimeout},
+		{"SERVER_READ_HEADER_TIMEOUT", c.ReadHeaderTimeout},
+		{"SERVER_WRITE_TIMEOUT", c.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", c.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be greater than zero", timeout.key))
+		}
+	}
+
+	for _, proxy := range c.TrustedProxyList() {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			problems = append(problems, fmt.Sprintf("TRUSTED_PROXIES: %q is not an IP address or CIDR range", proxy))
+		}
+	}
+	return problems
+}
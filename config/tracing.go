@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Supported values for TRACING_EXPORTER
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector. When empty the
+	// standard OTEL_EXPORTER_OTLP_* variables apply.
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+}
+
+func (c TracingConfig) validate() []string {
+	var problems []string
+	switch c.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING_EXPORTER: must be %s, %s or %s, got %q",
+			TracingExporterNone, TracingExporterStdout, TracingExporterOTLP, c.Exporter))
+	}
+	if c.Exporter != TracingExporterNone && c.ServiceName == "" {
+		problems = append(problems, "TRACING_SERVICE_NAME: must be set when tracing is enabled")
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("TRACING_SAMPLE_RATIO: must be between 0 and 1, got %g", c.SampleRatio))
+	}
+	return problems
+}
@@ -4,32 +4,104 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/services"
 	"github.com/gin-gonic/gin"
 )
 
-type HealthController struct{}
+type HealthController struct {
+	registry *services.HealthRegistry
+}
 
 type HealthResponse struct {
 	Status    string    `json:"status" example:"healthy"`
 	Timestamp time.Time `json:"timestamp" example:"2024-10-26T12:34:56.789Z"`
 }
 
-func NewHealthController() *HealthController {
-	return &HealthController{}
+type ComponentStatus struct {
+	Status    string  `json:"status" example:"up"`
+	Critical  bool    `json:"critical" example:"true"`
+	LatencyMS float64 `json:"latency_ms" example:"1.25"`
+	// Error is a generic reason; the cause is only logged, since driver
+	// errors can reveal hosts and credentials
+	Error string `json:"error,omitempty" example:"unavailable"`
+}
+
+type ReadinessResponse struct {
+	Status     string                     `json:"status" example:"ready"`
+	Timestamp  time.Time                  `json:"timestamp" example:"2024-10-26T12:34:56.789Z"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+func NewHealthController(registry *services.HealthRegistry) *HealthController {
+	return &HealthController{registry: registry}
 }
 
 // HealthCheck godoc
 // @Summary      Get health status
-// @Description  get the health status of the service
+// @Description  get the health status of the service. Kept for compatibility; same as /health/live
 // @Tags         health
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  HealthResponse
 // @Router       /health [get]
 func (hc *HealthController) HealthCheck(c *gin.Context) {
+	hc.Liveness(c)
+}
+
+// Liveness godoc
+// @Summary      Liveness probe
+// @Description  Reports that the process is running and able to serve requests. Dependencies are not probed.
+// @Tags         health
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  HealthResponse
+// @Router       /health/live [get]
+func (hc *HealthController) Liveness(c *gin.Context) {
 	response := HealthResponse{
 		Status:    "healthy",
 		Timestamp: time.Now(),
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+// Readiness godoc
+// @Summary      Readiness probe
+// @Description  Probes every registered dependency and reports per-component status and latency. Returns 503 when a critical check fails.
+// @Tags         health
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  ReadinessResponse
+// @Failure      503  {object}  ReadinessResponse
+// @Router       /health/ready [get]
+func (hc *HealthController) Readiness(c *gin.Context) {
+	report := hc.registry.Run(c.Request.Context())
+
+	components := make(map[string]ComponentStatus, len(report.Checks))
+	for _, check := range report.Checks {
+		component := ComponentStatus{
+			Status:    "up",
+			Critical:  check.Critical,
+			LatencyMS: float64(check.Latency.Microseconds()) / 1000,
+		}
+		if !check.Healthy {
+			component.Status = "down"
+			component.Error = "unavailable"
+			logging.FromContext(c.Request.Context()).Warn("readiness check failed",
+				"component", check.Name, "critical", check.Critical, "error", check.Err)
+		}
+		components[check.Name] = component
+	}
+
+	response := ReadinessResponse{
+		Status:     "ready",
+		Timestamp:  time.Now(),
+		Components: components,
+	}
+	status := http.StatusOK
+	if !report.Healthy {
+		response.Status = "not_ready"
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
@@ -0,0 +1,303 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/apikey"
+	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidAPIKeyID = apperror.BadRequest(apperror.CodeInvalidParameter, "API key ID must be a positive integer")
+
+// APIKeyController manages the API keys of one kind of owner. The owner's
+// ID is the :id path parameter and the key's ID is :keyID.
+type APIKeyController struct {
+	apiKeyService   services.APIKeyService
+	serviceAccounts bool
+}
+
+// NewUserAPIKeyController serves the keys under /users/:id
+func NewUserAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// NewServiceAccountAPIKeyController serves the keys under /service-accounts/:id
+func NewServiceAccountAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService, serviceAccounts: true}
+}
+
+// Create godoc
+// @Summary      Create API key
+// @Description  Issue an API key. The key is returned once and only stored hashed. Send it as
+// @Description  "Authorization: ApiKey <key>" or in the X-API-Key header. Scopes must be permissions the caller
+// @Description  holds; expires_at defaults to the configured lifetime and is capped by the maximum. Users can only
+// @Description  issue keys to themselves; service account keys require service_accounts:manage.
+// @Tags         v1/api-keys
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      uint                  true  "User or service account ID"
+// @Param        request  body      apikey.CreateRequest  true  "Key name, scopes and expiry"
+// @Success      201      {object}  apikey.CreateResponse
+// @Failure      400      {object}  common.Problem
+// @Failure      401      {object}  common.Problem
+// @Failure      403      {object}  common.Problem
+// @Failure      404      {object}  common.Problem
+// @Router       /api/v1/users/{id}/api-keys [post]
+// @Router       /api/v1/service-accounts/{id}/api-keys [post]
+func (kc *APIKeyController) Create(c *gin.Context) {
+	owner, err := kc.owner(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var req apikey.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, value, err := kc.apiKeyService.Create(c.Request.Context(), owner, c.GetUint(middleware.UserIDKey),
+		services.CreateAPIKeyInput{
+			Name:      req.Name,
+			Scopes:    req.Scopes,
+			ExpiresAt: req.ExpiresAt,
+		})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, apikey.CreateResponse{Response: newAPIKeyResponse(key), Key: value})
+}
+
+// List godoc
+// @Summary      List API keys
+// @Description  List the API keys of a user or service account, newest first. Users may list their own keys;
+// @Description  listing others requires api_keys:manage.
+// @Tags         v1/api-keys
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      uint  true  "User or service account ID"
+// @Success      200  {object}  apikey.ListResponse
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Router       /api/v1/users/{id}/api-keys [get]
+// @Router       /api/v1/service-accounts/{id}/api-keys [get]
+func (kc *APIKeyController) List(c *gin.Context) {
+	owner, err := kc.owner(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	keys, err := kc.apiKeyService.List(c.Request.Context(), owner)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	responses := make([]apikey.Response, len(keys))
+	for i := range keys {
+		responses[i] = newAPIKeyResponse(&keys[i])
+	}
+	c.JSON(http.StatusOK, apikey.ListResponse{APIKeys: responses})
+}
+
+// Get godoc
+// @Summary      Get API key
+// @Description  Get an API key. The secret part of the key is never returned.
+// @Tags         v1/api-keys
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      uint  true  "User or service account ID"
+// @Param        keyID  path      uint  true  "API key ID"
+// @Success      200    {object}  apikey.Response
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      403    {object}  common.Problem
+// @Failure      404    {object}  common.Problem
+// @Router       /api/v1/users/{id}/api-keys/{keyID} [get]
+// @Router       /api/v1/service-accounts/{id}/api-keys/{keyID} [get]
+func (kc *APIKeyController) Get(c *gin.Context) {
+	owner, id, err := kc.keyParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, err := kc.apiKeyService.Get(c.Request.Context(), owner, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAPIKeyResponse(key))
+}
+
+// Patch godoc
+// @Summary      Update API key
+// @Description  Rename an API key, replace its scopes or move its expiry, using JSON Merge Patch (RFC 7396).
+// @Description  The same rules as for creating a key apply.
+// @Tags         v1/api-keys
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      uint                 true  "User or service account ID"
+// @Param        keyID    path      uint                 true  "API key ID"
+// @Param        request  body      apikey.PatchRequest  true  "Members to change"
+// @Success      200      {object}  apikey.Response
+// @Failure      400      {object}  common.Problem
+// @Failure      401      {object}  common.Problem
+// @Failure      403      {object}  common.Problem
+// @Failure      404      {object}  common.Problem
+// @Failure      415      {object}  common.Problem
+// @Router       /api/v1/users/{id}/api-keys/{keyID} [patch]
+// @Router       /api/v1/service-accounts/{id}/api-keys/{keyID} [patch]
+func (kc *APIKeyController) Patch(c *gin.Context) {
+	owner, id, err := kc.keyParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var req apikey.PatchRequest
+	if err := bindMergePatch(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	input, err := apiKeyPatchInput(req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, err := kc.apiKeyService.Update(c.Request.Context(), owner, c.GetUint(middleware.UserIDKey), id, input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAPIKeyResponse(key))
+}
+
+// Delete godoc
+// @Summary      Revoke API key
+// @Description  Delete an API key. Requests using it are rejected from then on. Users may revoke their own keys;
+// @Description  revoking others requires api_keys:manage.
+// @Tags         v1/api-keys
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path      uint  true  "User or service account ID"
+// @Param        keyID  path      uint  true  "API key ID"
+// @Success      204    {object}  nil
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      403    {object}  common.Problem
+// @Failure      404    {object}  common.Problem
+// @Router       /api/v1/users/{id}/api-keys/{keyID} [delete]
+// @Router       /api/v1/service-accounts/{id}/api-keys/{keyID} [delete]
+func (kc *APIKeyController) Delete(c *gin.Context) {
+	owner, id, err := kc.keyParams(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := kc.apiKeyService.Delete(c.Request.Context(), owner, id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// owner parses the :id path parameter as a user or service account ID
+func (kc *APIKeyController) owner(c *gin.Context) (repositories.APIKeyOwner, error) {
+	if kc.serviceAccounts {
+		id, err := serviceAccountIDParam(c)
+		return repositories.APIKeyOwner{ServiceAccountID: id}, err
+	}
+	id, err := userIDParam(c)
+	return repositories.APIKeyOwner{UserID: id}, err
+}
+
+// keyParams parses the owner and the :keyID path parameter
+func (kc *APIKeyController) keyParams(c *gin.Context) (repositories.APIKeyOwner, uint, error) {
+	owner, err := kc.owner(c)
+	if err != nil {
+		return owner, 0, err
+	}
+	id, err := strconv.ParseUint(c.Param("keyID"), 10, 32)
+	if err != nil || id == 0 {
+		return owner, 0, errInvalidAPIKeyID
+	}
+	return owner, uint(id), nil
+}
+
+// apiKeyPatchInput validates the patch members. Every key field is
+// required, so none of them may be set to null or emptied.
+func apiKeyPatchInput(req apikey.PatchRequest) (services.UpdateAPIKeyInput, error) {
+	var input services.UpdateAPIKeyInput
+	var fields []apperror.FieldError
+	cannotRemove := func(field string) {
+		fields = append(fields, apperror.FieldError{Field: field, Code: "required", Message: "cannot be removed"})
+	}
+
+	switch {
+	case !req.Name.Set:
+	case req.Name.Null || req.Name.Value == "":
+		cannotRemove("name")
+	case len(req.Name.Value) > 100:
+		fields = append(fields, apperror.FieldError{Field: "name", Code: "max", Message: "must be at most 100 characters"})
+	default:
+		input.Name = &req.Name.Value
+	}
+
+	switch {
+	case !req.Scopes.Set:
+	case req.Scopes.Null:
+		cannotRemove("scopes")
+	default:
+		// Non-nil even when empty, so that the service rejects it
+		input.Scopes = append([]string{}, req.Scopes.Value...)
+	}
+
+	switch {
+	case !req.ExpiresAt.Set:
+	case req.ExpiresAt.Null:
+		cannotRemove("expires_at")
+	default:
+		expiresAt := req.ExpiresAt.Value
+		input.ExpiresAt = &expiresAt
+	}
+
+	if len(fields) > 0 {
+		return input, apperror.Validation("The request contains invalid fields", fields...)
+	}
+	return input, nil
+}
+
+func newAPIKeyResponse(key *models.APIKey) apikey.Response {
+	return apikey.Response{
+		ID:               key.ID,
+		Name:             key.Name,
+		Prefix:           key.Prefix,
+		Scopes:           key.ScopeList(),
+		UserID:           key.UserID,
+		ServiceAccountID: key.ServiceAccountID,
+		ExpiresAt:        key.ExpiresAt,
+		LastUsedAt:       key.LastUsedAt,
+		CreatedAt:        key.CreatedAt,
+		UpdatedAt:        key.UpdatedAt,
+	}
+}
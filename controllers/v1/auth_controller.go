@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/auth"
+	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	authService              services.AuthService
+	passwordResetService     services.PasswordResetService
+	emailVerificationService services.EmailVerificationService
+}
+
+func NewAuthController(authService services.AuthService, passwordResetService services.PasswordResetService,
+	emailVerificationService services.EmailVerificationService) *AuthController {
+	return &AuthController{
+		authService:              authService,
+		passwordResetService:     passwordResetService,
+		emailVerificationService: emailVerificationService,
+	}
+}
+
+// Login godoc
+// @Summary      Log in
+// @Description  Exchange a username (or email) and password for an access and refresh token pair. Users with
+// @Description  multi-factor authentication get 202 with a challenge token instead, to be sent with a code to
+// @Description  POST /api/v1/auth/mfa/verify. Repeated failures from an account or IP are answered with 429 and a
+// @Description  Retry-After header: first with growing delays, then with a temporary lockout.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Param        request body     auth.LoginRequest true "Credentials"
+// @Success      200    {object}  auth.TokenResponse
+// @Success      202    {object}  auth.MFAChallengeResponse
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      429    {object}  common.Problem
+// @Router       /api/v1/auth/login [post]
+func (ac *AuthController) Login(c *gin.Context) {
+	var req auth.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result, err := ac.authService.Login(c.Request.Context(), req.Username, req.Password, c.ClientIP())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if result.MFAToken != "" {
+		c.JSON(http.StatusAccepted, auth.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    result.MFAToken,
+			ExpiresIn:   int64(result.MFATokenExpiresIn.Seconds()),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, newTokenResponse(result.Tokens))
+}
+
+// Refresh godoc
+// @Summary      Refresh tokens
+// @Description  Rotate a refresh token: the presented token is revoked and a new pair is issued
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Param        request body     auth.RefreshRequest true "Refresh token"
+// @Success      200    {object}  auth.TokenResponse
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Router       /api/v1/auth/refresh [post]
+func (ac *AuthController) Refresh(c *gin.Context) {
+	var req auth.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	pair, err := ac.authService.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(pair))
+}
+
+// Logout godoc
+// @Summary      Log out
+// @Description  Revoke a refresh token so it can no longer be used
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Param        request body     auth.LogoutRequest true "Refresh token"
+// @Success      204    {object}  nil
+// @Failure      400    {object}  common.Problem
+// @Router       /api/v1/auth/logout [post]
+func (ac *AuthController) Logout(c *gin.Context) {
+	var req auth.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := ac.authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset
+// @Description  Email a single-use password reset link to the address. The response is the same whether or not
+// @Description  the address belongs to a user. Requests are limited per address and per client IP.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Param        request body     auth.ForgotPasswordRequest true "Account email"
+// @Success      202    {object}  nil
+// @Failure      400    {object}  common.Problem
+// @Failure      429    {object}  common.Problem
+// @Router       /api/v1/auth/password/forgot [post]
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+	var req auth.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := ac.passwordResetService.Forgot(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password with the token from a reset email. The password must satisfy the password
+// @Description  policy, which also rejects recent passwords. The token can be used once, and all refresh tokens
+// @Description  of the user are revoked.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Param        request body     auth.ResetPasswordRequest true "Reset token and new password"
+// @Success      204    {object}  nil
+// @Failure      400    {object}  common.Problem
+// @Router       /api/v1/auth/password/reset [post]
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+	var req auth.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := ac.passwordResetService.Reset(c.Request.Context(), req.Token, req.Password); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyEmail godoc
+// @Summary      Verify email
+// @Description  Confirm an email address with the signed link sent on signup or email change. Links stop working
+// @Description  when they expire or when the address changes again; following a link twice is harmless.
+// @Tags         v1/auth
+// @Produce      json
+// @Param        token  query    string true "Verification token from the link"
+// @Success      200    {object}  auth.VerifyEmailResponse
+// @Failure      400    {object}  common.Problem
+// @Router       /api/v1/auth/verify [get]
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		_ = c.Error(services.ErrInvalidVerificationToken)
+		return
+	}
+
+	user, err := ac.emailVerificationService.Verify(c.Request.Context(), token)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, auth.VerifyEmailResponse{
+		Email:           user.Email,
+		EmailVerifiedAt: *user.EmailVerifiedAt,
+	})
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Send a new verification link to the authenticated user's address. Requests are throttled; a 429
+// @Description  response carries Retry-After.
+// @Tags         v1/auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202    {object}  nil
+// @Failure      401    {object}  common.Problem
+// @Failure      409    {object}  common.Problem
+// @Failure      429    {object}  common.Problem
+// @Header       429    {integer} Retry-After "Seconds until a new link can be requested"
+// @Router       /api/v1/auth/verify/resend [post]
+func (ac *AuthController) ResendVerification(c *gin.Context) {
+	if err := ac.emailVerificationService.Resend(c.Request.Context(), c.GetUint(middleware.UserIDKey)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func newTokenResponse(pair *services.TokenPair) auth.TokenResponse {
+	return auth.TokenResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(pair.ExpiresIn.Seconds()),
+	}
+}
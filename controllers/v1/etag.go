@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/gin-gonic/gin"
+)
+
+// errETagMismatch is returned when If-Match names no current version. Weak
+// tags and lists of several tags never match, since If-Match requires a
+// strong comparison against the single version a write is made against.
+var errETagMismatch = apperror.PreconditionFailed(apperror.CodePrecondition,
+	"If-Match does not match the current version of the user")
+
+// userETag is a strong entity tag for the current version of a user
+func userETag(user *models.User) string {
+	return fmt.Sprintf(`"%d-%d"`, user.ID, user.Version)
+}
+
+// setUserETag adds the ETag header for user to the response
+func setUserETag(c *gin.Context, user *models.User) {
+	c.Header("ETag", userETag(user))
+}
+
+// ifMatchVersion returns the version required by the If-Match header of a
+// request on user id, or 0 when the header is absent or "*"
+func ifMatchVersion(c *gin.Context, id uint) (uint, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	var taggedID, version uint
+	if _, err := fmt.Sscanf(header, `"%d-%d"`, &taggedID, &version); err != nil || taggedID != id || version == 0 {
+		return 0, errETagMismatch
+	}
+	if header != fmt.Sprintf(`"%d-%d"`, taggedID, version) {
+		return 0, errETagMismatch
+	}
+	return version, nil
+}
+
+// notModified reports whether If-None-Match matches the user, in which case
+// it has already answered 304 Not Modified
+func notModified(c *gin.Context, user *models.User) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := userETag(user)
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses the weak comparison
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			setUserETag(c, user)
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutController struct {
+	lockoutService services.LockoutService
+}
+
+func NewLockoutController(lockoutService services.LockoutService) *LockoutController {
+	return &LockoutController{lockoutService: lockoutService}
+}
+
+// Unlock godoc
+// @Summary      Unlock user
+// @Description  Lift the login lockout of a user and forget their failed attempts. Lockouts of client IPs are not
+// @Description  affected. The unlock is recorded in the audit trail.
+// @Tags         v1/users
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id   path      uint  true  "User ID"
+// @Success      204  {object}  nil
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Router       /api/v1/users/{id}/unlock [post]
+func (lc *LockoutController) Unlock(c *gin.Context) {
+	id, err := userIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := lc.lockoutService.Unlock(c.Request.Context(), id, c.GetUint(middleware.UserIDKey)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/auth"
+	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+)
+
+type MFAController struct {
+	mfaService services.MFAService
+}
+
+func NewMFAController(mfaService services.MFAService) *MFAController {
+	return &MFAController{mfaService: mfaService}
+}
+
+// Status godoc
+// @Summary      MFA status
+// @Description  Report whether the authenticated user has multi-factor authentication enabled
+// @Tags         v1/auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200    {object}  auth.MFAStatusResponse
+// @Failure      401    {object}  common.Problem
+// @Router       /api/v1/auth/mfa [get]
+func (mc *MFAController) Status(c *gin.Context) {
+	status, err := mc.mfaService.Status(c.Request.Context(), c.GetUint(middleware.UserIDKey))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, auth.MFAStatusResponse{
+		Enabled:                status.Enabled,
+		RecoveryCodesRemaining: status.RecoveryCodesRemaining,
+	})
+}
+
+// Enroll godoc
+// @Summary      Start MFA enrolment
+// @Description  Generate a TOTP secret for the authenticated user. Add it to an authenticator app, usually by
+// @Description  rendering otpauth_uri as a QR code, then confirm with a code. Enrolling again before confirming
+// @Description  replaces the secret. Requires the password; wrong passwords count towards the login lockout.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body     auth.MFAEnrollRequest true "Current password"
+// @Success      200    {object}  auth.MFAEnrollResponse
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      409    {object}  common.Problem
+// @Failure      429    {object}  common.Problem
+// @Router       /api/v1/auth/mfa/enroll [post]
+func (mc *MFAController) Enroll(c *gin.Context) {
+	var req auth.MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	enrollment, err := mc.mfaService.Enroll(c.Request.Context(), c.GetUint(middleware.UserIDKey), req.Password,
+		c.ClientIP())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, auth.MFAEnrollResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.URI,
+	})
+}
+
+// Confirm godoc
+// @Summary      Confirm MFA enrolment
+// @Description  Enable multi-factor authentication with a code from the authenticator app. The response lists
+// @Description  one-time recovery codes; they are stored hashed and cannot be shown again.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body     auth.MFAConfirmRequest true "TOTP code"
+// @Success      200    {object}  auth.RecoveryCodesResponse
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      409    {object}  common.Problem
+// @Router       /api/v1/auth/mfa/confirm [post]
+func (mc *MFAController) Confirm(c *gin.Context) {
+	var req auth.MFAConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	codes, err := mc.mfaService.Confirm(c.Request.Context(), c.GetUint(middleware.UserIDKey), req.Code)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, auth.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable godoc
+// @Summary      Disable MFA
+// @Description  Turn off multi-factor authentication. Requires the password and a TOTP or recovery code. Wrong
+// @Description  passwords and codes count towards the login lockout.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body     auth.MFADisableRequest true "Password and code"
+// @Success      204    {object}  nil
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      409    {object}  common.Problem
+// @Failure      429    {object}  common.Problem
+// @Router       /api/v1/auth/mfa/disable [post]
+func (mc *MFAController) Disable(c *gin.Context) {
+	var req auth.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err := mc.mfaService.Disable(c.Request.Context(), c.GetUint(middleware.UserIDKey), req.Password, req.Code,
+		c.ClientIP())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Verify godoc
+// @Summary      Complete MFA login
+// @Description  Exchange the challenge token from login and a TOTP or recovery code for a token pair. Each code
+// @Description  and each challenge token is accepted once, and a new login replaces an unused challenge. Wrong
+// @Description  codes count towards the same lockout as wrong passwords.
+// @Tags         v1/auth
+// @Accept       json
+// @Produce      json
+// @Param        request body     auth.MFAVerifyRequest true "Challenge token and code"
+// @Success      200    {object}  auth.TokenResponse
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      429    {object}  common.Problem
+// @Router       /api/v1/auth/mfa/verify [post]
+func (mc *MFAController) Verify(c *gin.Context) {
+	var req auth.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	pair, err := mc.mfaService.CompleteLogin(c.Request.Context(), req.MFAToken, req.Code, c.ClientIP())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(pair))
+}
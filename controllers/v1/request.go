@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"encoding/json"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// MergePatchContentType is the media type of JSON Merge Patch documents
+const MergePatchContentType = "application/merge-patch+json"
+
+var errUnsupportedPatchType = apperror.New(apperror.KindUnsupportedMediaType, apperror.CodeUnsupportedMedia,
+	"PATCH requests must be sent as "+MergePatchContentType)
+
+// bindStrictJSON decodes the body into obj, rejecting unknown fields, and
+// runs the binding validations
+func bindStrictJSON(c *gin.Context, obj any) error {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// bindMergePatch decodes a JSON Merge Patch body. Plain application/json is
+// accepted as well, since many clients cannot set a custom media type.
+func bindMergePatch(c *gin.Context, obj any) error {
+	switch c.ContentType() {
+	case MergePatchContentType, binding.MIMEJSON:
+		return bindStrictJSON(c, obj)
+	default:
+		return errUnsupportedPatchType
+	}
+}
+
+// toInput validates the patch members. Every user field is required, so
+// none of them may be set to null or emptied.
+func (r UserPatchRequest) toInput() (services.UpdateUserInput, error) {
+	var input services.UpdateUserInput
+	var fields []apperror.FieldError
+
+	for _, member := range []struct {
+		name    string
+		field   common.PatchField[string]
+		isEmail bool
+		target  **string
+	}{
+		{"username", r.Username, false, &input.Username},
+		{"email", r.Email, true, &input.Email},
+		{"password", r.Password, false, &input.Password},
+	} {
+		switch {
+		case !member.field.Set:
+			continue
+		case member.field.Null:
+			fields = append(fields, apperror.FieldError{Field: member.name, Code: "required", Message: "cannot be removed"})
+		case member.field.Value == "":
+			fields = append(fields, apperror.FieldError{Field: member.name, Code: "required", Message: "is required"})
+		case member.isEmail && !isEmail(member.field.Value):
+			fields = append(fields, apperror.FieldError{Field: member.name, Code: "email", Message: "must be a valid email address"})
+		default:
+			value := member.field.Value
+			*member.target = &value
+		}
+	}
+
+	if r.CurrentPassword.Set && !r.CurrentPassword.Null {
+		current := r.CurrentPassword.Value
+		input.CurrentPassword = &current
+	}
+
+	if len(fields) > 0 {
+		return input, apperror.Validation("The request contains invalid fields", fields...)
+	}
+	return input, nil
+}
+
+// isEmail applies the same rule as the email binding tag
+func isEmail(value string) bool {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	return !ok || v.Var(value, "email") == nil
+}
@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/apikey"
+	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidServiceAccountID = apperror.BadRequest(apperror.CodeInvalidParameter, "Service account ID must be a positive integer")
+
+type ServiceAccountController struct {
+	serviceAccountService services.ServiceAccountService
+}
+
+func NewServiceAccountController(serviceAccountService services.ServiceAccountService) *ServiceAccountController {
+	return &ServiceAccountController{serviceAccountService: serviceAccountService}
+}
+
+// Create godoc
+// @Summary      Create service account
+// @Description  Create a service account to own API keys for jobs and integrations that do not act as a user
+// @Tags         v1/service-accounts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      apikey.ServiceAccountCreateRequest  true  "Service account name and description"
+// @Success      201      {object}  apikey.ServiceAccountResponse
+// @Failure      400      {object}  common.Problem
+// @Failure      401      {object}  common.Problem
+// @Failure      403      {object}  common.Problem
+// @Failure      409      {object}  common.Problem
+// @Router       /api/v1/service-accounts [post]
+func (sc *ServiceAccountController) Create(c *gin.Context) {
+	var req apikey.ServiceAccountCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	account, err := sc.serviceAccountService.Create(c.Request.Context(), c.GetUint(middleware.UserIDKey),
+		req.Name, req.Description)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, newServiceAccountResponse(account))
+}
+
+// List godoc
+// @Summary      List service accounts
+// @Description  List every service account ordered by name
+// @Tags         v1/service-accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  apikey.ServiceAccountListResponse
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Router       /api/v1/service-accounts [get]
+func (sc *ServiceAccountController) List(c *gin.Context) {
+	accounts, err := sc.serviceAccountService.List(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	responses := make([]apikey.ServiceAccountResponse, len(accounts))
+	for i := range accounts {
+		responses[i] = newServiceAccountResponse(&accounts[i])
+	}
+	c.JSON(http.StatusOK, apikey.ServiceAccountListResponse{ServiceAccounts: responses})
+}
+
+// Get godoc
+// @Summary      Get service account
+// @Description  Get a service account by ID
+// @Tags         v1/service-accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      uint  true  "Service account ID"
+// @Success      200  {object}  apikey.ServiceAccountResponse
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Router       /api/v1/service-accounts/{id} [get]
+func (sc *ServiceAccountController) Get(c *gin.Context) {
+	id, err := serviceAccountIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	account, err := sc.serviceAccountService.Get(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newServiceAccountResponse(account))
+}
+
+// Patch godoc
+// @Summary      Update service account
+// @Description  Rename a service account or change its description using JSON Merge Patch (RFC 7396). A null
+// @Description  description clears it; the name cannot be removed.
+// @Tags         v1/service-accounts
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      uint                               true  "Service account ID"
+// @Param        request  body      apikey.ServiceAccountPatchRequest  true  "Members to change"
+// @Success      200      {object}  apikey.ServiceAccountResponse
+// @Failure      400      {object}  common.Problem
+// @Failure      401      {object}  common.Problem
+// @Failure      403      {object}  common.Problem
+// @Failure      404      {object}  common.Problem
+// @Failure      409      {object}  common.Problem
+// @Failure      415      {object}  common.Problem
+// @Router       /api/v1/service-accounts/{id} [patch]
+func (sc *ServiceAccountController) Patch(c *gin.Context) {
+	id, err := serviceAccountIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	var req apikey.ServiceAccountPatchRequest
+	if err := bindMergePatch(c, &req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	input, err := serviceAccountPatchInput(req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	account, err := sc.serviceAccountService.Update(c.Request.Context(), id, input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newServiceAccountResponse(account))
+}
+
+// Delete godoc
+// @Summary      Delete service account
+// @Description  Delete a service account and revoke all of its API keys
+// @Tags         v1/service-accounts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      uint  true  "Service account ID"
+// @Success      204  {object}  nil
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Router       /api/v1/service-accounts/{id} [delete]
+func (sc *ServiceAccountController) Delete(c *gin.Context) {
+	id, err := serviceAccountIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := sc.serviceAccountService.Delete(c.Request.Context(), id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// serviceAccountIDParam parses the :id path parameter
+func serviceAccountIDParam(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		return 0, errInvalidServiceAccountID
+	}
+	return uint(id), nil
+}
+
+// serviceAccountPatchInput validates the patch members. The name is
+// required; the description may be cleared with null.
+func serviceAccountPatchInput(req apikey.ServiceAccountPatchRequest) (services.UpdateServiceAccountInput, error) {
+	var input services.UpdateServiceAccountInput
+	var fields []apperror.FieldError
+
+	switch {
+	case !req.Name.Set:
+	case req.Name.Null || req.Name.Value == "":
+		fields = append(fields, apperror.FieldError{Field: "name", Code: "required", Message: "cannot be removed"})
+	case len(req.Name.Value) > 100:
+		fields = append(fields, apperror.FieldError{Field: "name", Code: "max", Message: "must be at most 100 characters"})
+	default:
+		input.Name = &req.Name.Value
+	}
+
+	switch {
+	case !req.Description.Set:
+	case len(req.Description.Value) > 255:
+		fields = append(fields, apperror.FieldError{Field: "description", Code: "max", Message: "must be at most 255 characters"})
+	default:
+		// A null description decodes to the empty string
+		input.Description = &req.Description.Value
+	}
+
+	if len(fields) > 0 {
+		return input, apperror.Validation("The request contains invalid fields", fields...)
+	}
+	return input, nil
+}
+
+func newServiceAccountResponse(account *models.ServiceAccount) apikey.ServiceAccountResponse {
+	return apikey.ServiceAccountResponse{
+		ID:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		CreatedByID: account.CreatedByID,
+		CreatedAt:   account.CreatedAt,
+		UpdatedAt:   account.UpdatedAt,
+	}
+}
@@ -1,6 +1,10 @@
 package v1
 
-import "time"
+import (
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+)
 
 // UserCreateRequest represents the request body for creating a user
 type UserCreateRequest struct {
@@ -9,32 +13,62 @@ type UserCreateRequest struct {
 	Password string `json:"password" binding:"required" example:"secretpassword123"`
 }
 
-// UserUpdateRequest represents the request body for updating a user
-type UserUpdateRequest struct {
-	Username string `json:"username" example:"johndoe"`
-	Email    string `json:"email" binding:"email" example:"john@example.com"`
-	Password string `json:"password" example:"newpassword123"`
+// UserReplaceRequest represents the full user resource sent with PUT. The
+// password is write-only and left unchanged when omitted. CurrentPassword
+// confirms changes users make to their own password or email.
+type UserReplaceRequest struct {
+	Username        string  `json:"username" binding:"required" example:"johndoe"`
+	Email           string  `json:"email" binding:"required,email" example:"john@example.com"`
+	Password        *string `json:"password" binding:"omitempty,min=1" example:"newpassword123"`
+	CurrentPassword *string `json:"current_password" example:"secretpassword123"`
 }
 
-// UserResponse represents the response structure for user data
-type UserResponse struct {
-	ID        uint      `json:"id" example:"1"`
-	Username  string    `json:"username" example:"johndoe"`
-	Email     string    `json:"email" example:"john@example.com"`
-	CreatedAt time.Time `json:"created_at" example:"2024-10-26T12:34:56Z"`
-	UpdatedAt time.Time `json:"updated_at" example:"2024-10-26T12:34:56Z"`
+// UserPatchRequest represents a JSON Merge Patch of a user. Absent members
+// are left untouched. CurrentPassword is not a user field; it confirms
+// changes users make to their own password or email.
+type UserPatchRequest struct {
+	Username        common.PatchField[string] `json:"username" swaggertype:"string" example:"johndoe"`
+	Email           common.PatchField[string] `json:"email" swaggertype:"string" example:"john@example.com"`
+	Password        common.PatchField[string] `json:"password" swaggertype:"string" example:"newpassword123"`
+	CurrentPassword common.PatchField[string] `json:"current_password" swaggertype:"string" example:"secretpassword123"`
 }
 
-// ErrorResponse represents the error response structure
-type ErrorResponse struct {
-	Error   string `json:"error" example:"Invalid request parameters"`
-	Message string `json:"message,omitempty" example:"Email is already taken"`
+// UserResponse represents the response structure for user data
+type UserResponse struct {
+	ID              uint       `json:"id" example:"1"`
+	Username        string     `json:"username" example:"johndoe"`
+	Email           string     `json:"email" example:"john@example.com"`
+	CreatedAt       time.Time  `json:"created_at" example:"2024-10-26T12:34:56Z"`
+	UpdatedAt       time.Time  `json:"updated_at" example:"2024-10-26T12:34:56Z"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" example:"2024-11-02T08:00:00Z"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at" example:"2024-10-26T12:40:00Z"`
 }
 
 // ListUserResponse represents the paginated response for user listing
 type ListUserResponse struct {
 	Users      []UserResponse `json:"users"`
-	TotalCount int64          `json:"total_count" example:"100"`
-	Page       int            `json:"page" example:"1"`
+	TotalCount *int64         `json:"total_count,omitempty" example:"100"`
+	Page       int            `json:"page,omitempty" example:"1"`
 	PerPage    int            `json:"per_page" example:"10"`
+	NextCursor string         `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAyNC0xMC0yNlQxMjozNDo1NloiLCJpZCI6NDJ9"`
+}
+
+// UserImportRow reports the outcome of one imported row
+type UserImportRow struct {
+	Row    int                 `json:"row" example:"1"`
+	Status string              `json:"status" example:"failed" enums:"created,failed"`
+	ID     uint                `json:"id,omitempty" example:"42"`
+	Code   string              `json:"code,omitempty" example:"email_taken"`
+	Detail string              `json:"detail,omitempty" example:"Email is already taken"`
+	Errors []common.FieldError `json:"errors,omitempty"`
+}
+
+// UserImportReport summarises a bulk import. Error is set when the upload
+// could not be read to the end; rows up to that point are still reported.
+type UserImportReport struct {
+	Total   int             `json:"total" example:"3"`
+	Created int             `json:"created" example:"2"`
+	Failed  int             `json:"failed" example:"1"`
+	Rows    []UserImportRow `json:"rows"`
+	Error   string          `json:"error,omitempty" example:"Upload exceeds the 32 MiB import limit"`
 }
@@ -3,240 +3,270 @@ package v1
 import (
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
 	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/services"
 	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/common"
 	_ "github.com/canhbk/golang-gin-starter-kit/types/v1/user"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-type UserController struct{}
+var (
+	errInvalidUserID = apperror.BadRequest(apperror.CodeInvalidParameter, "User ID must be a positive integer")
+	errInvalidPaging = apperror.BadRequest(apperror.CodeInvalidParameter, "page and per_page must be integers")
+)
+
+type UserController struct {
+	userService services.UserService
+}
 
-func NewUserController() *UserController {
-	return &UserController{}
+func NewUserController(userService services.UserService) *UserController {
+	return &UserController{userService: userService}
 }
 
 // Create godoc
 // @Summary      Create user
-// @Description  Create a new user
+// @Description  Create a new user. The password must satisfy the password policy; violations are listed in errors.
 // @Tags         v1/users
 // @Accept       json
 // @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Param        request body     user.CreateRequest true "User Information"
 // @Success      201    {object}  user.Response
-// @Failure      400    {object}  common.ErrorResponse
-// @Failure      409    {object}  common.ErrorResponse
+// @Header       201    {string}  ETag "Version of the new user"
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      403    {object}  common.Problem
+// @Failure      409    {object}  common.Problem
 // @Router       /api/v1/users [post]
 func (uc *UserController) Create(c *gin.Context) {
 	var req UserCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-		})
-		return
-	}
-
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Internal server error",
-			Message: "Failed to process password",
-		})
+		_ = c.Error(err)
 		return
 	}
 
-	user := models.User{
+	user, err := uc.userService.Create(c.Request.Context(), services.CreateUserInput{
 		Username: req.Username,
 		Email:    req.Email,
-		Password: string(hashedPassword),
-	}
-
-	result := config.DB.Create(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Failed to create user",
-			Message: result.Error.Error(),
-		})
+		Password: req.Password,
+	})
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	})
+	setUserETag(c, user)
+	c.JSON(http.StatusCreated, newUserResponse(user))
 }
 
 // List godoc
 // @Summary      List users
-// @Description  Get paginated list of users
+// @Description  Get a paginated list of users. q searches usernames and emails; email, created_after and
+// @Description  created_before filter exactly; sort takes a comma separated list of id, username, email,
+// @Description  created_at and updated_at, each optionally prefixed with '-' for descending order.
+// @Description  Unknown sort fields and malformed values are rejected with 400; other parameters are ignored.
+// @Description  Passing cursor (empty for the first page) switches to keyset pagination on (created_at, id):
+// @Description  follow next_cursor until it is omitted. total_count is included by default in offset mode
+// @Description  and omitted in cursor mode; include_total overrides this. per_page is capped at 100.
 // @Tags         v1/users
 // @Accept       json
 // @Produce      json
-// @Param        request query    common.PaginationQuery false "Pagination params"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        request query    user.ListQuery false "Filter, sort and pagination params"
 // @Success      200    {object}  user.ListResponse
-// @Failure      400    {object}  common.ErrorResponse
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      403    {object}  common.Problem
 // @Router       /api/v1/users [get]
 func (uc *UserController) List(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
-
-	var users []models.User
-	var total int64
-
-	offset := (page - 1) * perPage
-
-	config.DB.Model(&models.User{}).Count(&total)
-	result := config.DB.Offset(offset).Limit(perPage).Find(&users)
+	input, err := parseUserListQuery(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Failed to fetch users",
-			Message: result.Error.Error(),
-		})
+	page, err := uc.userService.List(c.Request.Context(), input)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	userResponses := make([]UserResponse, len(users))
-	for i, user := range users {
-		userResponses[i] = UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		}
+	userResponses := make([]UserResponse, len(page.Users))
+	for i := range page.Users {
+		userResponses[i] = newUserResponse(&page.Users[i])
 	}
 
 	c.JSON(http.StatusOK, ListUserResponse{
 		Users:      userResponses,
-		TotalCount: total,
-		Page:       page,
-		PerPage:    perPage,
+		TotalCount: page.Total,
+		Page:       input.Page,
+		PerPage:    page.PerPage,
+		NextCursor: page.NextCursor,
 	})
 }
 
 // Get godoc
 // @Summary      Get user
-// @Description  Get user by ID
+// @Description  Get user by ID. Users may read their own record; reading others requires users:read
 // @Tags         v1/users
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "User ID"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id             path      uint    true   "User ID"
+// @Param        If-None-Match  header    string  false  "ETag from a previous response"
 // @Success      200  {object}  UserResponse
-// @Failure      404  {object}  ErrorResponse
+// @Header       200  {string}  ETag  "Current version of the user"
+// @Success      304  "The user has not changed"
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
 // @Router       /api/v1/users/{id} [get]
 func (uc *UserController) Get(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := userIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid user ID",
-			Message: "User ID must be a positive integer",
-		})
+		_ = c.Error(err)
 		return
 	}
 
-	var user models.User
-	result := config.DB.First(&user, id)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Error:   "User not found",
-			Message: "No user exists with the provided ID",
-		})
+	user, err := uc.userService.Get(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if notModified(c, user) {
 		return
 	}
 
-	c.JSON(http.StatusOK, UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	})
+	setUserETag(c, user)
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
 
 // Update godoc
-// @Summary      Update user
-// @Description  Update user by ID
+// @Summary      Replace user
+// @Description  Replace a user's username and email. The password is write-only and kept when omitted. Unknown fields
+// @Description  are rejected. Users may update their own record; updating others requires users:update. Users
+// @Description  changing their own password or email must send current_password. A new password revokes every
+// @Description  refresh token of the user, and a new email is reported to the previous address.
 // @Tags         v1/users
 // @Accept       json
 // @Produce      json
-// @Param        id      path    uint              true  "User ID"
-// @Param        request body    UserUpdateRequest true  "User Information"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id        path    uint                true   "User ID"
+// @Param        If-Match  header  string              false  "ETag the change is conditional on"
+// @Param        request   body    user.ReplaceRequest true   "User Information"
 // @Success      200     {object} UserResponse
-// @Failure      400     {object} ErrorResponse
-// @Failure      404     {object} ErrorResponse
+// @Header       200     {string} ETag "New version of the user"
+// @Failure      400     {object} common.Problem
+// @Failure      401     {object} common.Problem
+// @Failure      403     {object} common.Problem
+// @Failure      404     {object} common.Problem
+// @Failure      409     {object} common.Problem
+// @Failure      412     {object} common.Problem
 // @Router       /api/v1/users/{id} [put]
 func (uc *UserController) Update(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := userIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid user ID",
-			Message: "User ID must be a positive integer",
-		})
+		_ = c.Error(err)
 		return
 	}
 
-	var req UserUpdateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-		})
+	version, err := ifMatchVersion(c, id)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	var user models.User
-	result := config.DB.First(&user, id)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Error:   "User not found",
-			Message: "No user exists with the provided ID",
-		})
+	var req UserReplaceRequest
+	if err := bindStrictJSON(c, &req); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	// Update fields if provided
-	if req.Username != "" {
-		user.Username = req.Username
+	user, err := uc.userService.Update(c.Request.Context(), id, services.UpdateUserInput{
+		Username:        &req.Username,
+		Email:           &req.Email,
+		Password:        req.Password,
+		CurrentPassword: req.CurrentPassword,
+		ExpectedVersion: version,
+		ActorID:         c.GetUint(middleware.UserIDKey),
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
-	if req.Email != "" {
-		user.Email = req.Email
+
+	setUserETag(c, user)
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// Patch godoc
+// @Summary      Patch user
+// @Description  Apply a JSON Merge Patch (RFC 7396) to a user. Only the members present are changed; username and email
+// @Description  cannot be null. A new password must satisfy the password policy, which also rejects recent
+// @Description  passwords. Users may update their own record; updating others requires users:update. Users changing
+// @Description  their own password or email must send current_password, which is not stored. A new password
+// @Description  revokes every refresh token of the user, and a new email is reported to the previous address.
+// @Tags         v1/users
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id        path    uint              true   "User ID"
+// @Param        If-Match  header  string            false  "ETag the change is conditional on"
+// @Param        request   body    user.PatchRequest true   "Members to change"
+// @Success      200     {object} UserResponse
+// @Header       200     {string} ETag "New version of the user"
+// @Failure      400     {object} common.Problem
+// @Failure      401     {object} common.Problem
+// @Failure      403     {object} common.Problem
+// @Failure      404     {object} common.Problem
+// @Failure      409     {object} common.Problem
+// @Failure      415     {object} common.Problem
+// @Failure      412     {object} common.Problem
+// @Router       /api/v1/users/{id} [patch]
+func (uc *UserController) Patch(c *gin.Context) {
+	id, err := userIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
-	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
-				Error:   "Internal server error",
-				Message: "Failed to process password",
-			})
-			return
-		}
-		user.Password = string(hashedPassword)
+
+	version, err := ifMatchVersion(c, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	result = config.DB.Save(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Failed to update user",
-			Message: result.Error.Error(),
-		})
+	var req UserPatchRequest
+	if err := bindMergePatch(c, &req); err != nil {
+		_ = c.Error(err)
 		return
 	}
+	input, err := req.toInput()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	input.ExpectedVersion = version
+	input.ActorID = c.GetUint(middleware.UserIDKey)
 
-	c.JSON(http.StatusOK, UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	})
+	user, err := uc.userService.Update(c.Request.Context(), id, input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	setUserETag(c, user)
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
 
 // Delete godoc
@@ -245,28 +275,169 @@ func (uc *UserController) Update(c *gin.Context) {
 // @Tags         v1/users
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "User ID"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id        path      uint    true   "User ID"
+// @Param        If-Match  header    string  false  "ETag the delete is conditional on"
 // @Success      204  {object}  nil
-// @Failure      404  {object}  ErrorResponse
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Failure      412  {object}  common.Problem
 // @Router       /api/v1/users/{id} [delete]
 func (uc *UserController) Delete(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := userIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	version, err := ifMatchVersion(c, id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	err = uc.userService.Delete(c.Request.Context(), id, version)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeleted godoc
+// @Summary      List deleted users
+// @Description  Get a paginated list of soft-deleted users, most recently deleted first
+// @Tags         v1/users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        page      query    int  false  "Page number"  default(1)
+// @Param        per_page  query    int  false  "Page size, at most 100"  default(10)
+// @Success      200       {object} user.ListResponse
+// @Failure      400       {object} common.Problem
+// @Failure      401       {object} common.Problem
+// @Failure      403       {object} common.Problem
+// @Router       /api/v1/users/deleted [get]
+func (uc *UserController) ListDeleted(c *gin.Context) {
+	page, pageErr := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, perPageErr := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(services.DefaultUsersPerPage)))
+	if pageErr != nil || perPageErr != nil {
+		_ = c.Error(errInvalidPaging)
+		return
+	}
+
+	result, err := uc.userService.ListDeleted(c.Request.Context(), page, perPage)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	userResponses := make([]UserResponse, len(result.Users))
+	for i := range result.Users {
+		userResponses[i] = newUserResponse(&result.Users[i])
+	}
+
+	c.JSON(http.StatusOK, ListUserResponse{
+		Users:      userResponses,
+		TotalCount: result.Total,
+		Page:       page,
+		PerPage:    result.PerPage,
+	})
+}
+
+// Restore godoc
+// @Summary      Restore user
+// @Description  Undo the soft deletion of a user. Deleted users release their username and email, so the restore
+// @Description  fails with 409 username_taken or email_taken when another user has taken either since.
+// @Tags         v1/users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id   path      uint  true  "User ID"
+// @Success      200  {object}  UserResponse
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Failure      409  {object}  common.Problem
+// @Router       /api/v1/users/{id}/restore [post]
+func (uc *UserController) Restore(c *gin.Context) {
+	id, err := userIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error:   "Invalid user ID",
-			Message: "User ID must be a positive integer",
-		})
+		_ = c.Error(err)
 		return
 	}
 
-	result := config.DB.Delete(&models.User{}, id)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Error:   "User not found",
-			Message: "No user exists with the provided ID",
-		})
+	user, err := uc.userService.Restore(c.Request.Context(), id)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	setUserETag(c, user)
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// Purge godoc
+// @Summary      Permanently delete user
+// @Description  Hard-delete a soft-deleted user together with its tokens and role assignments. This cannot be undone.
+// @Tags         v1/users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        id   path      uint  true  "User ID"
+// @Success      204  {object}  nil
+// @Failure      400  {object}  common.Problem
+// @Failure      401  {object}  common.Problem
+// @Failure      403  {object}  common.Problem
+// @Failure      404  {object}  common.Problem
+// @Router       /api/v1/users/{id}/permanent [delete]
+func (uc *UserController) Purge(c *gin.Context) {
+	id, err := userIDParam(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := uc.userService.Purge(c.Request.Context(), id); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// userIDParam parses the :id path parameter
+func userIDParam(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		return 0, errInvalidUserID
+	}
+	return uint(id), nil
+}
+
+func newUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		Username:        user.Username,
+		Email:           user.Email,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       deletedAt(user.DeletedAt),
+		EmailVerifiedAt: user.EmailVerifiedAt,
+	}
+}
+
+func deletedAt(d gorm.DeletedAt) *time.Time {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Time
+}
@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/gin-gonic/gin"
+)
+
+// parseUserListQuery reads the listing parameters, reporting every invalid
+// one at once. Other parameters, such as cache busters, are ignored; the
+// service rejects unknown sort fields.
+func parseUserListQuery(c *gin.Context) (services.ListUsersInput, error) {
+	var fields []apperror.FieldError
+	invalid := func(field, code, message string) {
+		fields = append(fields, apperror.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	input := services.ListUsersInput{
+		Search: c.Query("q"),
+		Email:  c.Query("email"),
+		Sort:   c.Query("sort"),
+	}
+	// An empty cursor parameter asks for the first page in cursor mode
+	input.Cursor, input.UseCursor = c.GetQuery("cursor")
+
+	var err error
+	defaultPage := "1"
+	if input.UseCursor {
+		defaultPage = "0"
+	}
+	if input.Page, err = strconv.Atoi(c.DefaultQuery("page", defaultPage)); err != nil {
+		invalid("page", "integer", "must be an integer")
+	}
+	if input.PerPage, err = strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(services.DefaultUsersPerPage))); err != nil {
+		invalid("per_page", "integer", "must be an integer")
+	}
+	// Counting is skipped by default in cursor mode, where it would undo
+	// most of the benefit on large tables
+	if input.IncludeTotal, err = strconv.ParseBool(c.DefaultQuery("include_total", strconv.FormatBool(!input.UseCursor))); err != nil {
+		invalid("include_total", "boolean", "must be true or false")
+	}
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"created_after", &input.CreatedAfter},
+		{"created_before", &input.CreatedBefore},
+	} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		t, ok := parseTimeParam(raw)
+		if !ok {
+			invalid(param.name, "datetime", "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			continue
+		}
+		*param.target = &t
+	}
+
+	if len(fields) > 0 {
+		return input, apperror.Validation("The request contains invalid query parameters", fields...)
+	}
+	return input, nil
+}
+
+// parseTimeParam accepts a full RFC 3339 timestamp or a UTC calendar date
+func parseTimeParam(raw string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,460 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Media types accepted by the import and produced by the export
+const (
+	CSVContentType    = "text/csv"
+	NDJSONContentType = "application/x-ndjson"
+)
+
+const (
+	// importBatchSize is how many valid rows are inserted per transaction
+	importBatchSize = 100
+	// maxImportSize bounds the whole upload
+	maxImportSize = 32 << 20
+	// maxImportLineSize bounds a single NDJSON line
+	maxImportLineSize = 1 << 20
+	// transferDeadline is how long an import or export may take per batch.
+	// It replaces the server-wide read and write timeouts, which a bulk
+	// transfer as a whole is expected to outlive.
+	transferDeadline = time.Minute
+)
+
+// Import row statuses
+const (
+	importRowCreated = "created"
+	importRowFailed  = "failed"
+)
+
+// userCSVColumns are the columns of an exported CSV file
+var userCSVColumns = []string{"id", "username", "email", "created_at", "updated_at"}
+
+var (
+	errUnsupportedImportType = apperror.New(apperror.KindUnsupportedMediaType, apperror.CodeUnsupportedMedia,
+		"Imports must be sent as "+CSVContentType+" or "+NDJSONContentType)
+	errImportTooLarge = apperror.New(apperror.KindPayloadTooLarge, apperror.CodePayloadTooLarge,
+		"Upload exceeds the 32 MiB import limit")
+	errImportLineTooLong = apperror.BadRequest(apperror.CodeInvalidRequest, "NDJSON lines may not exceed 1 MiB")
+	errEmptyCSV          = apperror.BadRequest(apperror.CodeInvalidRequest, "CSV upload must start with a header row")
+	errInvalidFormat     = apperror.BadRequest(apperror.CodeInvalidParameter, "format must be csv or ndjson")
+)
+
+// importAborted is reported when a batch could not be inserted
+const importAborted = "Import stopped after an internal error; later rows were not processed"
+
+// Import godoc
+// @Summary      Import users
+// @Description  Create users in bulk from a CSV file with a username, email and password header (columns in
+// @Description  any order) or from NDJSON with one user object per line. Each row is validated like a single
+// @Description  create; valid rows are inserted in transactions of up to 100 rows. The report lists every row,
+// @Description  numbered from 1 without the CSV header. error is set when the upload could not be read to the
+// @Description  end, in which case rows up to that point have still been processed.
+// @Tags         v1/users
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        request body     string true "CSV or NDJSON rows"
+// @Success      200    {object}  user.ImportReport
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      403    {object}  common.Problem
+// @Failure      413    {object}  common.Problem
+// @Failure      415    {object}  common.Problem
+// @Router       /api/v1/users/import [post]
+func (uc *UserController) Import(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	rows, err := newImportReader(c.ContentType(), body)
+	if err != nil {
+		_ = c.Error(importReadError(err))
+		return
+	}
+
+	extendDeadlines(c)
+	imp := &userImport{ctx: c, service: uc.userService, report: UserImportReport{Rows: []UserImportRow{}}}
+	for {
+		req, rowErr, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			imp.report.Error = importReadError(err).Detail
+			break
+		}
+		if !imp.add(req, rowErr) {
+			break
+		}
+	}
+	imp.flush()
+
+	for _, row := range imp.report.Rows {
+		if row.Status == importRowCreated {
+			imp.report.Created++
+		} else {
+			imp.report.Failed++
+		}
+	}
+	imp.report.Total = len(imp.report.Rows)
+	c.JSON(http.StatusOK, imp.report)
+}
+
+// Export godoc
+// @Summary      Export users
+// @Description  Stream every active user as NDJSON (default) or CSV, in ID order. Passwords are never exported.
+// @Description  CSV cells starting with =, +, - or @ are prefixed with an apostrophe. If the export fails after
+// @Description  streaming has started, the connection is dropped before the response is complete.
+// @Tags         v1/users
+// @Produce      application/x-ndjson
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        format query    string false "Output format" Enums(ndjson, csv) default(ndjson)
+// @Success      200    {string}  string "One user per line"
+// @Failure      400    {object}  common.Problem
+// @Failure      401    {object}  common.Problem
+// @Failure      403    {object}  common.Problem
+// @Router       /api/v1/users/export [get]
+func (uc *UserController) Export(c *gin.Context) {
+	var out userExportWriter
+	switch c.DefaultQuery("format", "ndjson") {
+	case "ndjson":
+		out = &ndjsonExportWriter{encoder: json.NewEncoder(c.Writer)}
+	case "csv":
+		out = &csvExportWriter{writer: csv.NewWriter(c.Writer)}
+	default:
+		_ = c.Error(errInvalidFormat)
+		return
+	}
+
+	// Headers are only sent once the first query succeeded, so that an
+	// early failure can still be reported as a problem document
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		c.Header("Content-Type", out.contentType())
+		c.Header("Content-Disposition", `attachment; filename="users.`+out.extension()+`"`)
+		c.Status(http.StatusOK)
+		return out.begin()
+	}
+
+	err := uc.userService.Export(c.Request.Context(), func(users []models.User) error {
+		extendDeadlines(c)
+		if err := start(); err != nil {
+			return err
+		}
+		for i := range users {
+			if err := out.write(&users[i]); err != nil {
+				return err
+			}
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
+		err = start()
+		if err == nil {
+			err = out.flush()
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	if !started {
+		_ = c.Error(err)
+		return
+	}
+	// The status line is gone already. Dropping the connection without
+	// ending the body is the only way left to tell the client that the
+	// transfer is incomplete.
+	logging.FromContext(c.Request.Context()).Error("user export failed", "error", err)
+	panic(http.ErrAbortHandler)
+}
+
+// extendDeadlines gives the connection another transferDeadline to make
+// progress. Writers without deadline support keep the server timeouts.
+func extendDeadlines(c *gin.Context) {
+	rc := http.NewResponseController(c.Writer)
+	deadline := time.Now().Add(transferDeadline)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+}
+
+// userImport collects the report of an import and the valid rows waiting
+// to be inserted
+type userImport struct {
+	ctx     *gin.Context
+	service services.UserService
+	report  UserImportReport
+	pending []int
+	inputs  []services.CreateUserInput
+}
+
+// add records one row, validating it unless it was rejected while decoding,
+// and inserts the pending rows once a batch is full. It reports false when
+// the import has to stop.
+func (imp *userImport) add(req UserCreateRequest, rowErr error) bool {
+	imp.report.Rows = append(imp.report.Rows, UserImportRow{Row: len(imp.report.Rows) + 1})
+	row := len(imp.report.Rows) - 1
+
+	if rowErr == nil {
+		rowErr = binding.Validator.ValidateStruct(&req)
+	}
+	if rowErr != nil {
+		imp.fail(row, apperror.FromBinding(rowErr))
+		return true
+	}
+
+	imp.pending = append(imp.pending, row)
+	imp.inputs = append(imp.inputs, services.CreateUserInput{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+	})
+	if len(imp.pending) < importBatchSize {
+		return true
+	}
+	return imp.flush()
+}
+
+// flush inserts the pending rows. Failures that are not tied to a row end
+// the import: every pending row is marked as failed and the cause is logged.
+func (imp *userImport) flush() bool {
+	if len(imp.pending) == 0 {
+		return true
+	}
+	pending := imp.pending
+	extendDeadlines(imp.ctx)
+	results, err := imp.service.ImportBatch(imp.ctx.Request.Context(), imp.inputs)
+	imp.pending, imp.inputs = nil, nil
+	extendDeadlines(imp.ctx)
+
+	if err != nil {
+		logging.FromContext(imp.ctx.Request.Context()).Error("user import failed", "error", err)
+		for _, row := range pending {
+			imp.fail(row, apperror.Internal(err))
+		}
+		imp.report.Error = importAborted
+		return false
+	}
+
+	for n, row := range pending {
+		if results[n].Err != nil {
+			imp.fail(row, apperror.As(results[n].Err))
+			continue
+		}
+		imp.report.Rows[row].Status = importRowCreated
+		imp.report.Rows[row].ID = results[n].User.ID
+	}
+	return true
+}
+
+func (imp *userImport) fail(row int, err *apperror.Error) {
+	r := &imp.report.Rows[row]
+	r.Status = importRowFailed
+	r.Code = err.Code
+	r.Detail = err.Detail
+	for _, fe := range err.Fields {
+		r.Errors = append(r.Errors, common.FieldError{Field: fe.Field, Code: fe.Code, Message: fe.Message})
+	}
+}
+
+// importReader yields the rows of an upload. next returns io.EOF after the
+// last row. rowErr rejects only the current row, while err ends the import.
+type importReader interface {
+	next() (req UserCreateRequest, rowErr, err error)
+}
+
+func newImportReader(contentType string, body io.Reader) (importReader, error) {
+	switch contentType {
+	case CSVContentType:
+		return newCSVImportReader(body)
+	case NDJSONContentType, "application/ndjson":
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxImportLineSize)
+		return &ndjsonImportReader{scanner: scanner}, nil
+	default:
+		return nil, errUnsupportedImportType
+	}
+}
+
+// importReadError classifies a failure to read the upload itself
+func importReadError(err error) *apperror.Error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return errImportTooLarge
+	case errors.Is(err, bufio.ErrTooLong):
+		return errImportLineTooLong
+	default:
+		return apperror.FromBinding(err)
+	}
+}
+
+type csvImportReader struct {
+	reader *csv.Reader
+	// columns maps username, email and password to their position
+	columns map[string]int
+}
+
+func newCSVImportReader(body io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errEmptyCSV
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // byte order mark
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "username", "email", "password":
+		default:
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Unknown CSV column "+strconv.Quote(name))
+		}
+		if _, dup := columns[name]; dup {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "Duplicate CSV column "+strconv.Quote(name))
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"username", "email", "password"} {
+		if _, ok := columns[name]; !ok {
+			return nil, apperror.BadRequest(apperror.CodeInvalidRequest, "CSV header is missing the "+name+" column")
+		}
+	}
+	return &csvImportReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvImportReader) next() (UserCreateRequest, error, error) {
+	record, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &parseErr) && !errors.As(err, &maxBytesErr) {
+		return UserCreateRequest{}, apperror.BadRequest(apperror.CodeInvalidRequest, parseErr.Error()), nil
+	}
+	if err != nil {
+		return UserCreateRequest{}, nil, err
+	}
+	return UserCreateRequest{
+		Username: record[r.columns["username"]],
+		Email:    record[r.columns["email"]],
+		Password: record[r.columns["password"]],
+	}, nil, nil
+}
+
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r *ndjsonImportReader) next() (UserCreateRequest, error, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req UserCreateRequest
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			return req, err, nil
+		}
+		if decoder.More() {
+			return req, apperror.BadRequest(apperror.CodeInvalidRequest, "Each line must hold a single JSON object"), nil
+		}
+		return req, nil, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return UserCreateRequest{}, nil, err
+	}
+	return UserCreateRequest{}, nil, io.EOF
+}
+
+// userExportWriter encodes users in one of the export formats
+type userExportWriter interface {
+	contentType() string
+	extension() string
+	begin() error
+	write(user *models.User) error
+	flush() error
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonExportWriter) contentType() string { return NDJSONContentType }
+func (w *ndjsonExportWriter) extension() string   { return "ndjson" }
+func (w *ndjsonExportWriter) begin() error        { return nil }
+func (w *ndjsonExportWriter) flush() error        { return nil }
+
+func (w *ndjsonExportWriter) write(user *models.User) error {
+	return w.encoder.Encode(newUserResponse(user))
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) contentType() string { return CSVContentType + "; charset=utf-8" }
+func (w *csvExportWriter) extension() string   { return "csv" }
+func (w *csvExportWriter) begin() error        { return w.writer.Write(userCSVColumns) }
+
+func (w *csvExportWriter) write(user *models.User) error {
+	return w.writer.Write([]string{
+		strconv.FormatUint(uint64(user.ID), 10),
+		csvText(user.Username),
+		csvText(user.Email),
+		user.CreatedAt.UTC().Format(time.RFC3339),
+		user.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (w *csvExportWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// csvText prefixes a cell that spreadsheets would read as a formula with an
+// apostrophe, so that opening an export cannot run user-supplied formulas
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
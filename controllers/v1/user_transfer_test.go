@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/gin-gonic/gin"
+)
+
+// exportService passes users to the export in one batch and then fails
+// with err
+type exportService struct {
+	services.UserService
+	users []models.User
+	err   error
+}
+
+func (s exportService) Export(_ context.Context, fn func(users []models.User) error) error {
+	if err := fn(s.users); err != nil {
+		return err
+	}
+	return s.err
+}
+
+func exportServer(t *testing.T, service services.UserService) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler(), middleware.Recovery())
+	router.GET("/export", NewUserController(service).Export)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExportFailureMidStreamDropsTheConnection(t *testing.T) {
+	server := exportServer(t, exportService{
+		users: []models.User{{ID: 1, Username: "alice", Email: "alice@example.com"}},
+		err:   errors.New("connection reset"),
+	})
+
+	resp, err := http.Get(server.URL + "/export")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d once streaming started", resp.StatusCode, http.StatusOK)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		t.Fatalf("reading the body succeeded with %q, want an error for the incomplete transfer", body)
+	}
+	if !strings.Contains(string(body), `"username":"alice"`) {
+		t.Errorf("body = %q, want the batch sent before the failure", body)
+	}
+}
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	server := exportServer(t, exportService{
+		users: []models.User{{ID: 1, Username: "=HYPERLINK(1)", Email: "@example.com"}},
+	})
+
+	resp, err := http.Get(server.URL + "/export?format=csv")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("body = %q, want a header and one row", body)
+	}
+	if !strings.HasPrefix(lines[1], `1,'=HYPERLINK(1),'@example.com,`) {
+		t.Errorf("row = %q, want formula cells prefixed with an apostrophe", lines[1])
+	}
+}
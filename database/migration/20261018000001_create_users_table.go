@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// baselineUser is the users table as it was first created. Migrations keep
+// their own copy of the schema so that later model changes do not alter them.
+type baselineUser struct {
+	ID        uint   `gorm:"primarykey"`
+	Username  string `gorm:"size:255;not null;unique"`
+	Email     string `gorm:"size:255;not null;unique"`
+	Password  string `gorm:"size:255;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018000001",
+		Name:    "create_users_table",
+		Up: func(tx *gorm.DB) error {
+			// AutoMigrate leaves a table created before versioned
+			// migrations existed untouched
+			return tx.AutoMigrate(&baselineUser{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "users")
+		},
+	})
+}
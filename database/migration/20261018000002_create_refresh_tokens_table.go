@@ -0,0 +1,34 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type baselineRefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+	CreatedAt time.Time
+	User      baselineUser `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (baselineRefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018000002",
+		Name:    "create_refresh_tokens_table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&baselineRefreshToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "refresh_tokens")
+		},
+	})
+}
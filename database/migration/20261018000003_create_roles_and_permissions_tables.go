@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type baselinePermission struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"size:100;not null;unique"`
+	Description string `gorm:"size:255"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (baselinePermission) TableName() string {
+	return "permissions"
+}
+
+type baselineRole struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"size:100;not null;unique"`
+	Description string `gorm:"size:255"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (baselineRole) TableName() string {
+	return "roles"
+}
+
+type baselineRolePermission struct {
+	RoleID       uint               `gorm:"primaryKey"`
+	PermissionID uint               `gorm:"primaryKey"`
+	Role         baselineRole       `gorm:"constraint:OnDelete:CASCADE"`
+	Permission   baselinePermission `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (baselineRolePermission) TableName() string {
+	return "role_permissions"
+}
+
+type baselineUserRole struct {
+	UserID uint         `gorm:"primaryKey"`
+	RoleID uint         `gorm:"primaryKey"`
+	User   baselineUser `gorm:"constraint:OnDelete:CASCADE"`
+	Role   baselineRole `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (baselineUserRole) TableName() string {
+	return "user_roles"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018000003",
+		Name:    "create_roles_and_permissions_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&baselinePermission{},
+				&baselineRole{},
+				&baselineRolePermission{},
+				&baselineUserRole{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "user_roles", "role_permissions", "roles", "permissions")
+		},
+	})
+}
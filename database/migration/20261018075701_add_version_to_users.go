@@ -0,0 +1,30 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// userVersion adds the optimistic concurrency counter to users
+type userVersion struct {
+	Version uint `gorm:"not null;default:1"`
+}
+
+func (userVersion) TableName() string {
+	return "users"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018075701",
+		Name:    "add_version_to_users",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&userVersion{}, "Version") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&userVersion{}, "Version")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userVersion{}, "Version")
+		},
+	})
+}
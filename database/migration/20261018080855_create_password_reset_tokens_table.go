@@ -0,0 +1,34 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type passwordResetToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	User      baselineUser `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (passwordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018080855",
+		Name:    "create_password_reset_tokens_table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&passwordResetToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "password_reset_tokens")
+		},
+	})
+}
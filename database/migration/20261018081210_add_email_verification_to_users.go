@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// userEmailVerification adds the verification state to users
+type userEmailVerification struct {
+	EmailVerifiedAt    *time.Time
+	VerificationSentAt *time.Time
+}
+
+func (userEmailVerification) TableName() string {
+	return "users"
+}
+
+var userEmailVerificationColumns = []string{"EmailVerifiedAt", "VerificationSentAt"}
+
+func init() {
+	register(Migration{
+		Version: "20261018081210",
+		Name:    "add_email_verification_to_users",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range userEmailVerificationColumns {
+				if tx.Migrator().HasColumn(&userEmailVerification{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&userEmailVerification{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range userEmailVerificationColumns {
+				if err := tx.Migrator().DropColumn(&userEmailVerification{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
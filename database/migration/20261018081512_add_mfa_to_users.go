@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// userMFA adds the TOTP state to users
+type userMFA struct {
+	MFASecret    string `gorm:"size:255;not null;default:''"`
+	MFAEnabledAt *time.Time
+	MFALastStep  int64 `gorm:"not null;default:0"`
+}
+
+func (userMFA) TableName() string {
+	return "users"
+}
+
+var userMFAColumns = []string{"MFASecret", "MFAEnabledAt", "MFALastStep"}
+
+type recoveryCode struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	User      baselineUser `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (recoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018081512",
+		Name:    "add_mfa_to_users",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range userMFAColumns {
+				if tx.Migrator().HasColumn(&userMFA{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&userMFA{}, column); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&recoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, "recovery_codes"); err != nil {
+				return err
+			}
+			for _, column := range userMFAColumns {
+				if err := tx.Migrator().DropColumn(&userMFA{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
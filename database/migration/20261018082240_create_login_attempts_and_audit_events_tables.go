@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type loginAttempt struct {
+	Identifier   string    `gorm:"primarykey;size:255"`
+	Failures     int       `gorm:"not null;default:0"`
+	LastFailedAt time.Time `gorm:"not null;index"`
+	LockedUntil  *time.Time
+}
+
+func (loginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+type auditEvent struct {
+	ID        uint   `gorm:"primarykey"`
+	Action    string `gorm:"size:64;not null;index"`
+	Subject   string `gorm:"size:255;not null"`
+	UserID    *uint  `gorm:"index"`
+	ActorID   *uint
+	IP        string    `gorm:"size:45"`
+	Detail    string    `gorm:"size:255"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (auditEvent) TableName() string {
+	return "audit_events"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018082240",
+		Name:    "create_login_attempts_and_audit_events_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&loginAttempt{}, &auditEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "audit_events", "login_attempts")
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type passwordHistory struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"not null;index"`
+	PasswordHash string `gorm:"size:255;not null"`
+	CreatedAt    time.Time
+	User         baselineUser `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (passwordHistory) TableName() string {
+	return "password_histories"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018082757",
+		Name:    "create_password_histories_table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&passwordHistory{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "password_histories")
+		},
+	})
+}
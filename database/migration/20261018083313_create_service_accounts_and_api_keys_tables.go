@@ -0,0 +1,53 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type serviceAccount struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"size:100;not null;unique"`
+	Description string `gorm:"size:255"`
+	CreatedByID *uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (serviceAccount) TableName() string {
+	return "service_accounts"
+}
+
+type apiKey struct {
+	ID               uint      `gorm:"primarykey"`
+	Name             string    `gorm:"size:100;not null"`
+	Prefix           string    `gorm:"size:32;not null;uniqueIndex"`
+	SecretHash       string    `gorm:"size:64;not null"`
+	Scopes           string    `gorm:"size:2048;not null"`
+	UserID           *uint     `gorm:"index"`
+	ServiceAccountID *uint     `gorm:"index"`
+	ExpiresAt        time.Time `gorm:"not null"`
+	LastUsedAt       *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	User             *baselineUser   `gorm:"constraint:OnDelete:CASCADE"`
+	ServiceAccount   *serviceAccount `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+func (apiKey) TableName() string {
+	return "api_keys"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018083313",
+		Name:    "create_service_accounts_and_api_keys_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&serviceAccount{}, &apiKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "api_keys", "service_accounts")
+		},
+	})
+}
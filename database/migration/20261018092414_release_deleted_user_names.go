@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// liveUserIndexes replace the unique constraints on users.username and
+// users.email with indexes that only cover users that are not deleted, so a
+// deleted user releases its names
+var liveUserIndexes = []struct{ Constraint, Index, Column string }{
+	{"uni_users_username", "idx_users_username_live", "username"},
+	{"uni_users_email", "idx_users_email_live", "email"},
+}
+
+var (
+	// sqliteUserUniqueConstraint matches the table constraints GORM writes
+	// into the CREATE TABLE statement of the baseline users table
+	sqliteUserUniqueConstraint = regexp.MustCompile("\\s*,\\s*CONSTRAINT\\s+`?uni_users_(username|email)`?\\s+UNIQUE\\s*\\(`?(username|email)`?\\)")
+	sqliteUsersTableName       = regexp.MustCompile("^CREATE TABLE [`\"]?users[`\"]?")
+)
+
+func init() {
+	register(Migration{
+		Version:        "20261018092414",
+		Name:           "release_deleted_user_names",
+		RebuildsTables: true,
+		Up: func(tx *gorm.DB) error {
+			switch tx.Dialector.Name() {
+			case "sqlite":
+				if err := dropSQLiteUserConstraints(tx); err != nil {
+					return err
+				}
+				for _, idx := range liveUserIndexes {
+					// Down replaces the constraints with plain unique indexes
+					if err := tx.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", idx.Constraint)).Error; err != nil {
+						return err
+					}
+					err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON users (%s) WHERE deleted_at IS NULL", idx.Index, idx.Column)).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			case "postgres":
+				for _, idx := range liveUserIndexes {
+					if err := tx.Exec(fmt.Sprintf("ALTER TABLE users DROP CONSTRAINT IF EXISTS %s", idx.Constraint)).Error; err != nil {
+						return err
+					}
+					err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON users (%s) WHERE deleted_at IS NULL", idx.Index, idx.Column)).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			case "mysql":
+				// MySQL has no partial indexes. live is 1 for users that are
+				// not deleted and NULL otherwise, and a unique index treats
+				// NULLs as distinct.
+				err := tx.Exec("ALTER TABLE users ADD COLUMN live TINYINT AS (IF(deleted_at IS NULL, 1, NULL)) VIRTUAL").Error
+				if err != nil {
+					return err
+				}
+				for _, idx := range liveUserIndexes {
+					if err := tx.Exec(fmt.Sprintf("ALTER TABLE users DROP INDEX %s", idx.Constraint)).Error; err != nil {
+						return err
+					}
+					err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON users (%s, live)", idx.Index, idx.Column)).Error
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("unsupported dialect %q", tx.Dialector.Name())
+			}
+		},
+		Down: func(tx *gorm.DB) error {
+			// Fails while a deleted user shares a name with another user
+			for _, idx := range liveUserIndexes {
+				if err := tx.Migrator().DropIndex("users", idx.Index); err != nil {
+					return err
+				}
+				err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON users (%s)", idx.Constraint, idx.Column)).Error
+				if err != nil {
+					return err
+				}
+			}
+			if tx.Dialector.Name() == "mysql" {
+				return tx.Exec("ALTER TABLE users DROP COLUMN live").Error
+			}
+			return nil
+		},
+	})
+}
+
+// dropSQLiteUserConstraints rebuilds the users table without its unique
+// constraints, which SQLite cannot drop in place. It follows the procedure
+// SQLite documents for schema changes: copy into a new table, drop the old
+// one and rename the new one, with foreign key enforcement off.
+func dropSQLiteUserConstraints(tx *gorm.DB) error {
+	var ddl string
+	if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'users'").Scan(&ddl).Error; err != nil {
+		return err
+	}
+	var indexes []string
+	err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = 'users' AND sql IS NOT NULL").
+		Scan(&indexes).Error
+	if err != nil {
+		return err
+	}
+
+	ddl = sqliteUserUniqueConstraint.ReplaceAllString(ddl, "")
+	statements := []string{
+		sqliteUsersTableName.ReplaceAllString(ddl, "CREATE TABLE users_new"),
+		"INSERT INTO users_new SELECT * FROM users",
+		"DROP TABLE users",
+		"ALTER TABLE users_new RENAME TO users",
+	}
+	statements = append(statements, indexes...)
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
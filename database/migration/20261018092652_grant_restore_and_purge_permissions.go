@@ -0,0 +1,21 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	register(Migration{
+		Version: "20261018092652",
+		Name:    "grant_restore_and_purge_permissions",
+		Up: func(tx *gorm.DB) error {
+			return grantToAdmin(tx,
+				baselinePermission{Name: "users:restore", Description: "List and restore deleted user accounts"},
+				baselinePermission{Name: "users:purge", Description: "Permanently remove deleted user accounts"},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropPermissions(tx, "users:restore", "users:purge")
+		},
+	})
+}
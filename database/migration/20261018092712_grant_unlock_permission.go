@@ -0,0 +1,20 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	register(Migration{
+		Version: "20261018092712",
+		Name:    "grant_unlock_permission",
+		Up: func(tx *gorm.DB) error {
+			return grantToAdmin(tx,
+				baselinePermission{Name: "users:unlock", Description: "Lift login lockouts of user accounts"},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropPermissions(tx, "users:unlock")
+		},
+	})
+}
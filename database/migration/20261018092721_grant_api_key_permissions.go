@@ -0,0 +1,21 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	register(Migration{
+		Version: "20261018092721",
+		Name:    "grant_api_key_permissions",
+		Up: func(tx *gorm.DB) error {
+			return grantToAdmin(tx,
+				baselinePermission{Name: "api_keys:manage", Description: "List and revoke the API keys of any user"},
+				baselinePermission{Name: "service_accounts:manage", Description: "Manage service accounts and their API keys"},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropPermissions(tx, "api_keys:manage", "service_accounts:manage")
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// userMFAChallenge adds the pending login challenge to users
+type userMFAChallenge struct {
+	MFAChallenge string `gorm:"size:64;not null;default:''"`
+}
+
+func (userMFAChallenge) TableName() string {
+	return "users"
+}
+
+func init() {
+	register(Migration{
+		Version: "20261018093226",
+		Name:    "add_mfa_challenge_to_users",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&userMFAChallenge{}, "MFAChallenge") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&userMFAChallenge{}, "MFAChallenge")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userMFAChallenge{}, "MFAChallenge")
+		},
+	})
+}
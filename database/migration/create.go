@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var nameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+var migrationTemplate = template.Must(template.New("migration").Parse(`package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	register(Migration{
+		Version: "{{.Version}}",
+		Name:    "{{.Name}}",
+		Up: func(tx *gorm.DB) error {
+			// TODO: apply the schema change
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// TODO: revert the schema change
+			return nil
+		},
+	})
+}
+`))
+
+// Create writes a new, empty migration unit into dir and returns its path.
+// The CLI has to be rebuilt before the new migration can be applied.
+func Create(dir, name string) (string, error) {
+	name = strings.Trim(nameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", fmt.Errorf("migration name must contain letters or digits")
+	}
+
+	version := time.Now().UTC().Format("20060102150405")
+	path := filepath.Join(dir, version+"_"+name+".go")
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data := struct{ Version, Name string }{version, name}
+	if err := migrationTemplate.Execute(file, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -1,40 +1,269 @@
 package migration
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"time"
 
 	"github.com/canhbk/golang-gin-starter-kit/config"
-	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
 )
 
-// AutoMigrate will create or modify tables based on models
-func AutoMigrate() {
-	log.Println("Running database migrations...")
+// Migration is a single, reversible schema change. Up and Down receive a
+// transaction and must only touch the schema through it.
+type Migration struct {
+	Version string
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+	// RebuildsTables runs the migration with SQLite foreign key enforcement
+	// turned off, which SQLite requires for rebuilding a table that others
+	// reference. Other drivers ignore it.
+	RebuildsTables bool
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version   string
+	Name      string
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema_migrations tracking table
+type schemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:14"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+var registry []Migration
+
+// register adds a migration unit. It is called from the init function of
+// every migration file.
+func register(m Migration) {
+	registry = append(registry, m)
+}
+
+// migrations returns the registered units ordered by version
+func migrations() []Migration {
+	sorted := make([]Migration, len(registry))
+	copy(sorted, registry)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted
+}
 
-	err := config.DB.AutoMigrate(
-		&models.User{},
-		// Add more models here
-	)
+// Up applies up to steps pending migrations in order. A steps value of zero
+// applies every pending migration.
+func Up(steps int) error {
+	log.Println("Running database migrations...")
 
+	applied, err := appliedVersions()
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
+		return err
+	}
+
+	count := 0
+	for _, m := range migrations() {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if steps > 0 && count == steps {
+			break
+		}
+
+		log.Printf("Applying %s_%s", m.Version, m.Name)
+		err := transaction(m, func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
 	}
 
-	log.Println("Database migration completed successfully")
+	log.Printf("Database migration completed successfully (%d applied)", count)
+	return nil
 }
 
-// Rollback will drop all tables
-func Rollback() {
+// Down reverts up to steps of the most recently applied migrations. A steps
+// value of zero reverts every applied migration.
+func Down(steps int) error {
 	log.Println("Rolling back database migrations...")
 
-	err := config.DB.Migrator().DropTable(
-		&models.User{},
-		// Add more models here
-	)
+	applied, err := appliedVersions()
+	if err != nil {
+		return err
+	}
+
+	all := migrations()
+	count := 0
+	for i := len(all) - 1; i >= 0; i-- {
+		m := all[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if steps > 0 && count == steps {
+			break
+		}
+
+		log.Printf("Reverting %s_%s", m.Version, m.Name)
+		err := transaction(m, func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: m.Version}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("rollback of %s_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	log.Printf("Database rollback completed successfully (%d reverted)", count)
+	return nil
+}
 
+// Statuses lists every registered migration with the time it was applied
+func Statuses() ([]Status, error) {
+	applied, err := appliedVersions()
 	if err != nil {
-		log.Fatalf("Failed to rollback database: %v", err)
+		return nil, err
 	}
 
-	log.Println("Database rollback completed successfully")
+	var statuses []Status
+	for _, m := range migrations() {
+		status := Status{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// appliedVersions creates the tracking table when needed and returns the
+// applied migrations keyed by version
+func appliedVersions() (map[string]schemaMigration, error) {
+	if config.DB == nil {
+		return nil, errors.New("database is not initialized")
+	}
+	if err := config.DB.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to prepare schema_migrations: %w", err)
+	}
+
+	var rows []schemaMigration
+	if err := config.DB.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// transaction runs fn for m in a transaction. A migration that rebuilds
+// tables on SQLite runs on a single connection with foreign key enforcement
+// off, and the foreign keys are checked before it commits.
+func transaction(m Migration, fn func(tx *gorm.DB) error) error {
+	if !m.RebuildsTables || config.DB.Dialector.Name() != "sqlite" {
+		return config.DB.Transaction(fn)
+	}
+	return config.DB.Connection(func(conn *gorm.DB) error {
+		// The pragma is a no-op inside a transaction
+		if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := fn(tx); err != nil {
+				return err
+			}
+			var violations []map[string]interface{}
+			if err := tx.Raw("PRAGMA foreign_key_check").Scan(&violations).Error; err != nil {
+				return err
+			}
+			if len(violations) > 0 {
+				return fmt.Errorf("%d rows violate foreign keys", len(violations))
+			}
+			return nil
+		})
+	})
+}
+
+// dropTables drops the named tables in the given order. Migrator.DropTable
+// walks its arguments backwards, which is surprising for plain table names.
+func dropTables(tx *gorm.DB, tables ...string) error {
+	for _, table := range tables {
+		if err := tx.Migrator().DropTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adminRole is the role that migrations grant new permissions to
+const adminRole = "admin"
+
+// grantToAdmin creates permissions added after the initial seed and grants
+// them to the admin role when it exists. Fresh databases get both from the
+// seeder instead.
+func grantToAdmin(tx *gorm.DB, permissions ...baselinePermission) error {
+	var roleIDs []uint
+	if err := tx.Model(&baselineRole{}).Where("name = ?", adminRole).Pluck("id", &roleIDs).Error; err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		err := tx.Where(baselinePermission{Name: permission.Name}).
+			Attrs(baselinePermission{Description: permission.Description}).
+			FirstOrCreate(&permission).Error
+		if err != nil {
+			return err
+		}
+		for _, roleID := range roleIDs {
+			var count int64
+			err := tx.Model(&baselineRolePermission{}).
+				Where("role_id = ? AND permission_id = ?", roleID, permission.ID).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			grant := map[string]interface{}{"role_id": roleID, "permission_id": permission.ID}
+			if err := tx.Model(&baselineRolePermission{}).Create(grant).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dropPermissions removes the named permissions from every role and then
+// deletes them
+func dropPermissions(tx *gorm.DB, names ...string) error {
+	ids := tx.Model(&baselinePermission{}).Select("id").Where("name IN ?", names)
+	if err := tx.Where("permission_id IN (?)", ids).Delete(&baselineRolePermission{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("name IN ?", names).Delete(&baselinePermission{}).Error
 }
@@ -6,44 +6,50 @@ import (
 
 	"github.com/canhbk/golang-gin-starter-kit/config"
 	"github.com/canhbk/golang-gin-starter-kit/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/canhbk/golang-gin-starter-kit/password"
 )
 
-// RunSeeders executes all seeders
-func RunSeeders() {
+// RunSeeders executes all seeders. Seeded passwords are hashed with hasher
+// but, being well-known demo values, bypass the password policy.
+func RunSeeders(hasher password.Hasher) {
 	log.Println("Running database seeders...")
 
 	// Run individual seeders
-	seedUsers()
+	seedRoles()
+	seedUsers(hasher)
 	// Add more seeder functions here
 
 	log.Println("Database seeding completed successfully")
 }
 
 // seedUsers creates initial user records
-func seedUsers() {
+func seedUsers(hasher password.Hasher) {
 	log.Println("Seeding users...")
 
 	// Hash password for users
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	hashedPassword, err := hasher.Hash("password123")
 	if err != nil {
 		log.Fatalf("Failed to hash password: %v", err)
 	}
 
+	// Seeded addresses are placeholders, so they start out verified
+	verifiedAt := time.Now()
 	users := []models.User{
 		{
-			Username:  "admin",
-			Email:     "admin@example.com",
-			Password:  string(hashedPassword),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Username:        "admin",
+			Email:           "admin@example.com",
+			Password:        hashedPassword,
+			EmailVerifiedAt: &verifiedAt,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
 		},
 		{
-			Username:  "user",
-			Email:     "user@example.com",
-			Password:  string(hashedPassword),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			Username:        "user",
+			Email:           "user@example.com",
+			Password:        hashedPassword,
+			EmailVerifiedAt: &verifiedAt,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
 		},
 	}
 
@@ -53,4 +59,45 @@ func seedUsers() {
 			log.Printf("Error seeding user %s: %v", user.Email, result.Error)
 		}
 	}
+
+	// Grant the admin role to the admin user
+	var admin models.User
+	var adminRole models.Role
+	if err := config.DB.Where("username = ?", "admin").First(&admin).Error; err != nil {
+		log.Printf("Error loading admin user: %v", err)
+		return
+	}
+	if err := config.DB.Where("name = ?", models.RoleAdmin).First(&adminRole).Error; err != nil {
+		log.Printf("Error loading admin role: %v", err)
+		return
+	}
+	if err := config.DB.Model(&admin).Association("Roles").Append(&adminRole); err != nil {
+		log.Printf("Error assigning admin role: %v", err)
+	}
+}
+
+// seedRoles creates the known permissions and the admin role holding all of them
+func seedRoles() {
+	log.Println("Seeding roles and permissions...")
+
+	permissions := make([]models.Permission, 0, len(models.DefaultPermissions))
+	for _, permission := range models.DefaultPermissions {
+		result := config.DB.Where(models.Permission{Name: permission.Name}).
+			Assign(models.Permission{Description: permission.Description}).
+			FirstOrCreate(&permission)
+		if result.Error != nil {
+			log.Printf("Error seeding permission %s: %v", permission.Name, result.Error)
+			continue
+		}
+		permissions = append(permissions, permission)
+	}
+
+	role := models.Role{Name: models.RoleAdmin, Description: "Full access to all resources"}
+	if err := config.DB.FirstOrCreate(&role, models.Role{Name: role.Name}).Error; err != nil {
+		log.Printf("Error seeding role %s: %v", role.Name, err)
+		return
+	}
+	if err := config.DB.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		log.Printf("Error assigning permissions to role %s: %v", role.Name, err)
+	}
 }
@@ -0,0 +1,51 @@
+// Package testutil holds helpers shared by the tests of several packages
+package testutil
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/database/migration"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// NewDB opens a fresh SQLite database in a temporary directory and applies
+// every migration to it. config.DB points at it for the duration of the
+// test, since the migrations run against it.
+func NewDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	cfg := config.DBConfig{Driver: config.DriverSQLite, DBName: filepath.Join(t.TempDir(), "test.db")}
+	dialector, err := cfg.Dialector()
+	if err != nil {
+		t.Fatalf("configure database: %v", err)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		_ = sqlDB.Close()
+	})
+
+	// The migrations report their progress through the standard logger
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(output)
+	if err := migration.Up(0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
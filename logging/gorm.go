@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger sends GORM logs to slog. Queries are logged at debug level,
+// slow queries at warn level and failures at error level, each tagged with
+// the request ID carried by the query context. Statements are logged with
+// placeholders, since bound values include password hashes, token hashes
+// and MFA secrets.
+type GormLogger struct {
+	logger        *slog.Logger
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+func NewGormLogger(logger *slog.Logger, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{
+		logger:        logger,
+		level:         gormlogger.Info,
+		slowThreshold: slowThreshold,
+	}
+}
+
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.log(ctx, slog.LevelInfo, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.log(ctx, slog.LevelWarn, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.log(ctx, slog.LevelError, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level := slog.LevelDebug
+	msg := "sql query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		level, msg = slog.LevelError, "sql query failed"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		level, msg = slog.LevelWarn, "slow sql query"
+	case l.level < gormlogger.Info:
+		return
+	}
+
+	logger := l.loggerFor(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.Log(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter drops the bound values of every statement before it is
+// logged, like GORM's ParameterizedQueries option
+func (l *GormLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+func (l *GormLogger) log(ctx context.Context, level slog.Level, msg string) {
+	l.loggerFor(ctx).Log(ctx, level, msg)
+}
+
+// loggerFor annotates the logger with the request ID carried by ctx
+func (l *GormLogger) loggerFor(ctx context.Context) *slog.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return l.logger.With("request_id", requestID)
+	}
+	return l.logger
+}
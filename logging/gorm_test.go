@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type secret struct {
+	ID    uint
+	Value string `gorm:"unique"`
+}
+
+func TestGormLoggerOmitsBoundValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(logger, time.Second)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&secret{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	buf.Reset()
+	if err := db.Create(&secret{Value: "hunter2"}).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// A failed insert is logged at error level
+	if err := db.Create(&secret{Value: "hunter2"}).Error; err == nil {
+		t.Fatal("duplicate insert succeeded")
+	}
+
+	var levels []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry struct {
+			Level string `json:"level"`
+			SQL   string `json:"sql"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		if strings.Contains(entry.SQL, "hunter2") || !strings.Contains(entry.SQL, "?") {
+			t.Errorf("%s entry logs %q, want placeholders only", entry.Level, entry.SQL)
+		}
+		levels = append(levels, entry.Level)
+	}
+	if len(levels) != 2 || levels[1] != "ERROR" {
+		t.Errorf("logged levels %v, want a debug and an error entry", levels)
+	}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+// Supported values for LOG_FORMAT
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New builds a structured logger writing to w at the given level
+// (debug, info, warn or error) and format (json or text)
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected %s or %s)", format, FormatJSON, FormatText)
+	}
+}
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the default logger annotated with the request ID and,
+// when a span is active, the trace and span IDs in ctx
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String(), "span_id", span.SpanID().String())
+	}
+	return logger
+}
@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender stores each message as an .eml file, which mail clients can
+// open directly
+type FileSender struct {
+	from string
+	dir  string
+}
+
+// NewFileSender creates dir if needed. Messages may carry credentials such
+// as reset links, so the directory and files are private to the process user.
+func NewFileSender(from, dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileSender{from: from, dir: dir}, nil
+}
+
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	name := now.Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(msg.Body)
+
+	return os.WriteFile(filepath.Join(s.dir, name), buf.Bytes(), 0o600)
+}
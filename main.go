@@ -1,35 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/canhbk/golang-gin-starter-kit/config"
 	_ "github.com/canhbk/golang-gin-starter-kit/docs" // This is required for swagger
+	"github.com/canhbk/golang-gin-starter-kit/mail"
+	"github.com/canhbk/golang-gin-starter-kit/metrics"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
 	"github.com/canhbk/golang-gin-starter-kit/routes"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/tracing"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	swaggerFiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// Package level variable
-var logger *log.Logger
-
-// init function runs before main
-func init() {
-	// Setup logger
-	logger = log.New(os.Stdout, "[INIT] ", log.LstdFlags)
-	logger.Println("Starting initialization...")
-
-	// Load environment variables from .env file
-	if err := godotenv.Load(); err != nil {
-		logger.Printf("Warning: .env file not found")
-	} else {
-		logger.Printf("Environment variables loaded successfully")
-	}
-}
-
 // @title           Example API
 // @version         1.0
 // @description     A backend service for Example platform
@@ -46,35 +42,156 @@ func init() {
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
 func main() {
-	logger := log.New(os.Stdout, "[MAIN] ", log.LstdFlags)
-	logger.Println("Starting main function...")
+	// Load configuration from defaults, config file, .env and environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Setup the structured logger
+	config.InitializeLogger(cfg.Log)
+	slog.Info("Configuration loaded")
+
+	// Send gin's debug output through the structured logger
+	gin.SetMode(cfg.Server.Mode)
+	gin.DebugPrintFunc = func(format string, values ...interface{}) {
+		slog.Debug("gin", "message", fmt.Sprintf(format, values...))
+	}
+	gin.DebugPrintRouteFunc = func(httpMethod, absolutePath, handlerName string, nuHandlers int) {
+		slog.Debug("route registered", "method", httpMethod, "path", absolutePath, "handler", handlerName)
+	}
+
+	// Install the tracer provider before anything starts creating spans
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	slog.Info("Tracing initialized", "exporter", cfg.Tracing.Exporter)
 
 	// Initialize database connection
-	config.InitializeDB()
-	logger.Println("Database initialized")
+	config.InitializeDB(cfg.Database)
+	if err := config.DB.Use(tracing.NewGormPlugin()); err != nil {
+		log.Fatalf("Failed to register GORM tracing plugin: %v", err)
+	}
+	slog.Info("Database initialized")
+
+	// Load JWT settings used to sign and verify tokens
+	config.InitializeJWT(cfg.Security.JWT)
+	slog.Info("JWT initialized")
+
+	// Outgoing mail, used for password reset links
+	mailer, err := mail.NewSender(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to initialize mail sender: %v", err)
+	}
+	slog.Info("Mail sender initialized", "driver", cfg.Mail.Driver)
+
+	// HTTP metrics are created first, since their middleware is global
+	var registry *prometheus.Registry
+	var httpMetrics *metrics.HTTPMetrics
+	if cfg.Metrics.Enabled {
+		registry = metrics.NewRegistry()
+		httpMetrics = metrics.NewHTTPMetrics(registry)
+	}
 
 	// Initialize Gin router
-	router := gin.Default()
-	logger.Println("Gin router initialized")
+	router, err := newRouter(cfg, httpMetrics)
+	if err != nil {
+		log.Fatalf("Failed to initialize router: %v", err)
+	}
+	slog.Info("Gin router initialized")
+
+	// Prometheus metrics for HTTP traffic and the database pool
+	if cfg.Metrics.Enabled {
+		if err := metrics.RegisterDBStats(registry, config.DB, cfg.Database.DBName); err != nil {
+			slog.Error("Failed to register database metrics", "error", err)
+		}
+		router.GET(cfg.Metrics.Path, metrics.Handler(registry))
+		slog.Info("Metrics initialized", "path", cfg.Metrics.Path)
+	}
 
 	// Initialize routes
-	routes.InitializeRoutes(router)
-	logger.Println("Routes initialized")
+	background := services.NewBackground()
+	if err := routes.InitializeRoutes(router, config.DB, cfg, mailer, background); err != nil {
+		log.Fatalf("Failed to initialize routes: %v", err)
+	}
+	slog.Info("Routes initialized")
 
 	// Swagger documentation route
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	logger.Println("Swagger documentation initialized")
-
-	// Get port from environment variable or use default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	slog.Info("Swagger documentation initialized")
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
 	}
 
 	// Start server
-	logger.Printf("Starting server on port %s...", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	go func() {
+		slog.Info("Starting server", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Failed to start server", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Wait for an interrupt or termination signal
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop()
+
+	// Stop accepting connections and drain in-flight requests
+	slog.Info("Shutting down server", "grace_period", cfg.Server.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server forced to shut down", "error", err)
+	}
+	// Let mail sent on behalf of finished requests go out before the
+	// database closes
+	if err := background.Wait(shutdownCtx); err != nil {
+		slog.Error("Background work did not finish", "error", err)
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	config.CloseDB()
+	slog.Info("Server exited")
+}
+
+// newRouter creates the engine and its global middleware. The metrics
+// middleware, when given, wraps the error handler so that it records the
+// status of the problem responses written there.
+func newRouter(cfg *config.Config, httpMetrics *metrics.HTTPMetrics) (*gin.Engine, error) {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxyList()); err != nil {
+		return nil, fmt.Errorf("set trusted proxies: %w", err)
+	}
+
+	handlers := []gin.HandlerFunc{
+		otelgin.Middleware(cfg.Tracing.ServiceName),
+		middleware.RequestID(),
+	}
+	if httpMetrics != nil {
+		handlers = append(handlers, httpMetrics.Middleware())
 	}
+	handlers = append(handlers,
+		middleware.Logger(),
+		middleware.ErrorHandler(),
+		middleware.Recovery(),
+	)
+	router.Use(handlers...)
+	return router, nil
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/metrics"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// TestMetricsRecordProblemStatus checks that requests failed through
+// c.Error are counted with the status the error handler writes
+func TestMetricsRecordProblemStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := metrics.NewRegistry()
+	router, err := newRouter(config.Default(), metrics.NewHTTPMetrics(registry))
+	if err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+	router.NoRoute(middleware.NoRoute())
+	router.GET("/invalid", func(c *gin.Context) {
+		_ = c.Error(apperror.Validation("The request contains invalid fields"))
+	})
+
+	for _, path := range []string{"/invalid", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	counted := map[string]string{}
+	for _, family := range families {
+		if family.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counted[labels["route"]] = labels["status"]
+		}
+	}
+
+	want := map[string]string{"/invalid": "400", "unmatched": "404"}
+	for route, status := range want {
+		if counted[route] != status {
+			t.Errorf("route %s counted with status %q, want %q (all: %v)", route, counted[route], status, counted)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// unmatchedRoute labels requests that did not match any route, so that
+// arbitrary paths cannot blow up label cardinality
+const unmatchedRoute = "unmatched"
+
+// NewRegistry returns a registry holding the Go runtime and process collectors
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// HTTPMetrics records request counts and latencies
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+func NewHTTPMetrics(registerer prometheus.Registerer) *HTTPMetrics {
+	labels := []string{"method", "route", "status"}
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests by route template, method and status.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by route template, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		}),
+	}
+	registerer.MustRegister(m.requests, m.duration, m.inFlight)
+	return m
+}
+
+// Middleware observes every request. The route label is the route template
+// such as /api/v1/users/:id rather than the raw path.
+func (m *HTTPMetrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		labels := prometheus.Labels{
+			"method": c.Request.Method,
+			"route":  route,
+			"status": strconv.Itoa(c.Writer.Status()),
+		}
+		m.requests.With(labels).Inc()
+		m.duration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDBStats exports the sql.DBStats of the pool behind db, including
+// open, in-use and idle connections and the wait count
+func RegisterDBStats(registerer prometheus.Registerer, db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return registerer.Register(collectors.NewDBStatsCollector(sqlDB, name))
+}
+
+// Handler serves the registry in the Prometheus text exposition format
+func Handler(gatherer prometheus.Gatherer) gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+}
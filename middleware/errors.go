@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProblemContentType is the media type of RFC 7807 error documents
+const ProblemContentType = "application/problem+json"
+
+var registerFieldNames sync.Once
+
+// ErrorHandler renders the last error attached with c.Error as a problem
+// document. Handlers and middleware report failures with c.Error (or
+// AbortWithError) and leave the response to this middleware, which must run
+// before them in the chain.
+func ErrorHandler() gin.HandlerFunc {
+	registerFieldNames.Do(useJSONFieldNames)
+
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		appErr := apperror.FromBinding(c.Errors.Last().Err)
+		status := statusFor(appErr.Kind)
+		if appErr.Kind == apperror.KindInternal {
+			logging.FromContext(c.Request.Context()).Error("request failed",
+				slog.String("code", appErr.Code),
+				slog.Any("error", appErr.Err),
+			)
+		}
+
+		problem := common.Problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    appErr.Detail,
+			Instance:  c.Request.URL.Path,
+			Code:      appErr.Code,
+			RequestID: c.GetString(RequestIDKey),
+		}
+		for _, field := range appErr.Fields {
+			problem.Errors = append(problem.Errors, common.FieldError{
+				Field:   field.Field,
+				Code:    field.Code,
+				Message: field.Message,
+			})
+		}
+
+		if appErr.RetryAfter > 0 {
+			// Round up so clients never retry too early
+			seconds := (appErr.RetryAfter + time.Second - 1) / time.Second
+			c.Header("Retry-After", strconv.FormatInt(int64(seconds), 10))
+		}
+
+		body, _ := json.Marshal(problem)
+		c.Data(status, ProblemContentType, body)
+	}
+}
+
+// AbortWithError records err for ErrorHandler and stops the chain
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// NoRoute reports unmatched paths as problem documents
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		AbortWithError(c, apperror.NotFound(apperror.CodeNotFound, "The requested resource does not exist"))
+	}
+}
+
+func statusFor(kind apperror.Kind) int {
+	switch kind {
+	case apperror.KindBadRequest, apperror.KindValidation:
+		return http.StatusBadRequest
+	case apperror.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperror.KindForbidden:
+		return http.StatusForbidden
+	case apperror.KindNotFound:
+		return http.StatusNotFound
+	case apperror.KindConflict:
+		return http.StatusConflict
+	case apperror.KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case apperror.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case apperror.KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case apperror.KindTooManyRequests:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// useJSONFieldNames makes validation errors report fields by their JSON name
+func useJSONFieldNames() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Logger writes one structured access log entry per request. It must run
+// after RequestID so that the entry carries the request ID.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if userID := c.GetUint(UserIDKey); userID != 0 {
+			attrs = append(attrs, slog.Uint64("user_id", uint64(userID)))
+		}
+		if keyID := c.GetUint(APIKeyIDKey); keyID != 0 {
+			attrs = append(attrs, slog.Uint64("api_key_id", uint64(keyID)))
+		}
+		if accountID := c.GetUint(ServiceAccountIDKey); accountID != 0 {
+			attrs = append(attrs, slog.Uint64("service_account_id", uint64(accountID)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).Log(ctx, level, "http request", attrs...)
+	}
+}
+
+// Recovery turns panics into 500 problem documents and logs them with the
+// request ID. It must run after ErrorHandler. http.ErrAbortHandler is passed
+// on, so that net/http drops the connection as the handler asked.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+		logging.FromContext(c.Request.Context()).Error("panic recovered",
+			slog.Any("panic", recovered),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("stack", string(debug.Stack())),
+		)
+		AbortWithError(c, apperror.Internal(fmt.Errorf("panic: %v", recovered)))
+	})
+}
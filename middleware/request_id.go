@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader is read from incoming requests and echoed on responses
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the context key holding the request ID
+	RequestIDKey = "requestID"
+)
+
+// RequestID reuses a well-formed X-Request-ID header or generates a new ID,
+// and stores it on the gin context and the request context
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// validRequestID accepts short IDs made of characters that are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
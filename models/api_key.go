@@ -0,0 +1,36 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey authenticates requests without a login. It belongs to either a
+// user or a service account. The secret is only stored hashed; Prefix is
+// the public part of the key used to look it up.
+type APIKey struct {
+	ID         uint   `gorm:"primarykey" json:"id"`
+	Name       string `gorm:"size:100;not null" json:"name"`
+	Prefix     string `gorm:"size:32;not null;uniqueIndex" json:"prefix"`
+	SecretHash string `gorm:"size:64;not null" json:"-"`
+	// Scopes is the space separated list of permissions the key grants
+	Scopes           string          `gorm:"size:2048;not null" json:"scopes"`
+	UserID           *uint           `gorm:"index" json:"user_id,omitempty"`
+	ServiceAccountID *uint           `gorm:"index" json:"service_account_id,omitempty"`
+	ExpiresAt        time.Time       `gorm:"not null" json:"expires_at"`
+	LastUsedAt       *time.Time      `json:"last_used_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	User             *User           `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	ServiceAccount   *ServiceAccount `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ScopeList returns the permissions the key grants
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// IsExpired reports whether the key can no longer be used
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit actions
+const (
+	AuditAccountLocked   = "account.locked"
+	AuditAccountUnlocked = "account.unlocked"
+	AuditIPLocked        = "ip.locked"
+)
+
+// AuditEvent records a security-relevant event. It deliberately has no
+// foreign keys, so that the trail outlives purged users.
+type AuditEvent struct {
+	ID     uint   `gorm:"primarykey" json:"id"`
+	Action string `gorm:"size:64;not null;index" json:"action"`
+	// Subject is what the event concerns, in the form used by LoginAttempt
+	Subject string `gorm:"size:255;not null" json:"subject"`
+	// UserID is the affected account, when the subject is a known user
+	UserID *uint `gorm:"index" json:"user_id,omitempty"`
+	// ActorID is the user who caused the event; nil for automatic events
+	ActorID   *uint     `json:"actor_id,omitempty"`
+	IP        string    `gorm:"size:45" json:"ip,omitempty"`
+	Detail    string    `gorm:"size:255" json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt counts the recent failed logins of one account or client IP.
+// Identifier names what is counted, such as "user:42" or "ip:203.0.113.7".
+type LoginAttempt struct {
+	Identifier   string     `gorm:"primarykey;size:255" json:"identifier"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time  `gorm:"not null;index" json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether attempts are refused until LockedUntil
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory keeps a hash a user had before changing their password,
+// so that it cannot be chosen again
+type PasswordHistory struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	PasswordHash string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	User         User      `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
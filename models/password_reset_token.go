@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// IsActive reports whether the token can still be used to reset the password
+func (t *PasswordResetToken) IsActive(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
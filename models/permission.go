@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Permission names checked by the authorization middleware
+const (
+	PermissionUsersCreate  = "users:create"
+	PermissionUsersList    = "users:list"
+	PermissionUsersRead    = "users:read"
+	PermissionUsersUpdate  = "users:update"
+	PermissionUsersDelete  = "users:delete"
+	PermissionUsersRestore = "users:restore"
+	PermissionUsersPurge   = "users:purge"
+	PermissionUsersUnlock  = "users:unlock"
+
+	PermissionAPIKeysManage         = "api_keys:manage"
+	PermissionServiceAccountsManage = "service_accounts:manage"
+)
+
+type Permission struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;not null;unique" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DefaultPermissions lists every permission known to the application
+var DefaultPermissions = []Permission{
+	{Name: PermissionUsersCreate, Description: "Create user accounts"},
+	{Name: PermissionUsersList, Description: "List all user accounts"},
+	{Name: PermissionUsersRead, Description: "Read any user account"},
+	{Name: PermissionUsersUpdate, Description: "Update any user account"},
+	{Name: PermissionUsersDelete, Description: "Delete any user account"},
+	{Name: PermissionUsersRestore, Description: "List and restore deleted user accounts"},
+	{Name: PermissionUsersPurge, Description: "Permanently remove deleted user accounts"},
+	{Name: PermissionUsersUnlock, Description: "Lift login lockouts of user accounts"},
+	{Name: PermissionAPIKeysManage, Description: "List and revoke the API keys of any user"},
+	{Name: PermissionServiceAccountsManage, Description: "Manage service accounts and their API keys"},
+}
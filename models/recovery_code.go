@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a one-time code that replaces a TOTP code when the user
+// has lost their authenticator
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
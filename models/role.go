@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+const RoleAdmin = "admin"
+
+type Role struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	Name        string       `gorm:"size:100;not null;unique" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE" json:"permissions,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
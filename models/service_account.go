@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// ServiceAccount is a non-human owner of API keys, for jobs and integrations
+// that act on their own behalf rather than a user's
+type ServiceAccount struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:100;not null;unique" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	CreatedByID *uint     `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -7,11 +7,32 @@ import (
 )
 
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	Username  string         `gorm:"size:255;not null;unique" json:"username"`
-	Email     string         `gorm:"size:255;not null;unique" json:"email"`
+	ID uint `gorm:"primarykey" json:"id"`
+	// Username and Email are unique among users that are not deleted, so a
+	// deleted user releases them
+	Username  string         `gorm:"size:255;not null;uniqueIndex:idx_users_username_live,where:deleted_at IS NULL" json:"username"`
+	Email     string         `gorm:"size:255;not null;uniqueIndex:idx_users_email_live,where:deleted_at IS NULL" json:"email"`
 	Password  string         `gorm:"size:255;not null" json:"-"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	// EmailVerifiedAt is set once the user confirms their address and
+	// cleared whenever the address changes
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// VerificationSentAt is when the last verification email was sent and
+	// throttles resends
+	VerificationSentAt *time.Time `json:"-"`
+	// MFASecret is the encrypted TOTP secret. It is stored at enrolment,
+	// but MFA is only enforced once MFAEnabledAt is set by a confirmed code.
+	MFASecret    string     `gorm:"size:255;not null;default:''" json:"-"`
+	MFAEnabledAt *time.Time `json:"-"`
+	// MFALastStep is the TOTP time step of the last accepted code, so that
+	// a code cannot be used twice
+	MFALastStep int64 `gorm:"not null;default:0" json:"-"`
+	// MFAChallenge is the hash of the ID of the latest login challenge. It
+	// is cleared when the challenge is used, so that it works once.
+	MFAChallenge string `gorm:"size:64;not null;default:''" json:"-"`
+	// Version is bumped on every update and backs the ETag of the resource
+	Version uint   `gorm:"not null;default:1" json:"-"`
+	Roles   []Role `gorm:"many2many:user_roles;constraint:OnDelete:CASCADE" json:"roles,omitempty"`
 }
@@ -0,0 +1,78 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2idPrefix     = "$argon2id$"
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+// argon2idScheme produces hashes in the PHC string format used by the
+// reference implementation: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type argon2idScheme struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func newArgon2idScheme(memory, iterations, parallelism int) *argon2idScheme {
+	return &argon2idScheme{memory: uint32(memory), iterations: uint32(iterations), parallelism: uint8(parallelism)}
+}
+
+func (s *argon2idScheme) recognizes(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+func (s *argon2idScheme) hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, s.iterations, s.memory, s.parallelism, argon2idKeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		s.memory, s.iterations, s.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (s *argon2idScheme) verify(password, hash string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownHash
+	}
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrUnknownHash
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return false, false, nil
+	}
+	weaker := memory < s.memory || iterations < s.iterations || len(key) < argon2idKeyLength
+	return true, weaker, nil
+}
@@ -0,0 +1,45 @@
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type bcryptScheme struct {
+	cost int
+}
+
+func newBcryptScheme(cost int) *bcryptScheme {
+	return &bcryptScheme{cost: cost}
+}
+
+// recognizes matches the $2a$, $2b$ and $2y$ variants
+func (s *bcryptScheme) recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+func (s *bcryptScheme) hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (s *bcryptScheme) verify(password, hash string) (bool, bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, err
+	}
+	return true, cost < s.cost, nil
+}
@@ -0,0 +1,74 @@
+// Package password hashes passwords and enforces the password policy.
+// Hashes name their algorithm and parameters, so that bcrypt and Argon2id
+// hashes verify side by side while the configured algorithm changes.
+package password
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/canhbk/golang-gin-starter-kit/config"
+)
+
+// ErrUnknownHash is returned for a stored hash no scheme recognises
+var ErrUnknownHash = errors.New("unrecognised password hash")
+
+// Hasher hashes new passwords with the configured algorithm and verifies
+// passwords against hashes of any supported algorithm
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash, and whether a match
+	// should be rehashed because hash uses another algorithm or weaker
+	// parameters than configured
+	Verify(password, hash string) (match, rehash bool, err error)
+}
+
+// scheme is one hashing algorithm with its configured parameters
+type scheme interface {
+	// recognizes reports whether hash was produced by this algorithm
+	recognizes(hash string) bool
+	hash(password string) (string, error)
+	// verify reports whether password matches hash, and whether hash uses
+	// weaker parameters than the scheme
+	verify(password, hash string) (match, weaker bool, err error)
+}
+
+type hasher struct {
+	current scheme
+	schemes []scheme
+}
+
+// NewHasher builds a hasher for cfg.Algorithm
+func NewHasher(cfg config.PasswordConfig) (Hasher, error) {
+	bcrypt := newBcryptScheme(cfg.BcryptCost)
+	argon2id := newArgon2idScheme(cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+
+	h := &hasher{schemes: []scheme{bcrypt, argon2id}}
+	switch cfg.Algorithm {
+	case config.PasswordAlgorithmBcrypt:
+		h.current = bcrypt
+	case config.PasswordAlgorithmArgon2id:
+		h.current = argon2id
+	default:
+		return nil, fmt.Errorf("unsupported password algorithm %q", cfg.Algorithm)
+	}
+	return h, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	return h.current.hash(password)
+}
+
+func (h *hasher) Verify(password, hash string) (bool, bool, error) {
+	for _, s := range h.schemes {
+		if !s.recognizes(hash) {
+			continue
+		}
+		match, weaker, err := s.verify(password, hash)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, s != h.current || weaker, nil
+	}
+	return false, false, ErrUnknownHash
+}
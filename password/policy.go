@@ -0,0 +1,115 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+)
+
+// Field is the request field that policy violations are reported against
+const Field = "password"
+
+//go:embed banned.txt
+var builtinBanned string
+
+// Policy decides which passwords users may choose
+type Policy struct {
+	cfg    config.PasswordConfig
+	banned map[string]struct{}
+}
+
+// NewPolicy builds the policy from cfg, reading cfg.BannedFile if set
+func NewPolicy(cfg config.PasswordConfig) (*Policy, error) {
+	p := &Policy{cfg: cfg, banned: make(map[string]struct{})}
+	if err := p.addBanned(strings.NewReader(builtinBanned)); err != nil {
+		return nil, err
+	}
+
+	if cfg.BannedFile != "" {
+		f, err := os.Open(cfg.BannedFile)
+		if err != nil {
+			return nil, fmt.Errorf("open banned password file: %w", err)
+		}
+		defer f.Close()
+		if err := p.addBanned(f); err != nil {
+			return nil, fmt.Errorf("read banned password file: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// addBanned reads one password per line, skipping blank lines and lines
+// starting with #
+func (p *Policy) addBanned(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.banned[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check returns every rule password breaks. identities are account values
+// the password must not repeat, such as the username and email.
+func (p *Policy) Check(password string, identities ...string) []apperror.FieldError {
+	var violations []apperror.FieldError
+	add := func(code, message string) {
+		violations = append(violations, apperror.FieldError{Field: Field, Code: code, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		add("too_short", fmt.Sprintf("must be at least %d characters", p.cfg.MinLength))
+	}
+	if len(password) > p.cfg.MaxLength {
+		add("too_long", fmt.Sprintf("must be at most %d bytes", p.cfg.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.cfg.RequireUpper && !upper {
+		add("missing_upper", "must contain an uppercase letter")
+	}
+	if p.cfg.RequireLower && !lower {
+		add("missing_lower", "must contain a lowercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		add("missing_digit", "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		add("missing_symbol", "must contain a symbol")
+	}
+
+	folded := strings.ToLower(password)
+	if _, ok := p.banned[folded]; ok {
+		add("banned", "is too common")
+	}
+	for _, identity := range identities {
+		if identity != "" && folded == strings.ToLower(identity) {
+			add("matches_account", "must not be the username or email")
+			break
+		}
+	}
+	return violations
+}
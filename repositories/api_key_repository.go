@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyOwner selects the keys of a user or of a service account. Exactly
+// one of the IDs is set.
+type APIKeyOwner struct {
+	UserID           uint
+	ServiceAccountID uint
+}
+
+func (o APIKeyOwner) scope(db *gorm.DB) *gorm.DB {
+	if o.ServiceAccountID != 0 {
+		return db.Where("service_account_id = ?", o.ServiceAccountID)
+	}
+	return db.Where("user_id = ?", o.UserID)
+}
+
+// APIKeyRepository provides persistence for API keys
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	FindByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	// Find returns the key only if it belongs to owner
+	Find(ctx context.Context, owner APIKeyOwner, id uint) (*models.APIKey, error)
+	// List returns the owner's keys, newest first
+	List(ctx context.Context, owner APIKeyOwner) ([]models.APIKey, error)
+	Update(ctx context.Context, key *models.APIKey, columns ...string) error
+	Delete(ctx context.Context, owner APIKeyOwner, id uint) error
+	// Touch records that the key was used at now, unless that was already
+	// recorded after since
+	Touch(ctx context.Context, id uint, now, since time.Time) error
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return translateError(r.db.WithContext(ctx).Create(key).Error)
+}
+
+func (r *apiKeyRepository) FindByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) Find(ctx context.Context, owner APIKeyOwner, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := owner.scope(r.db.WithContext(ctx)).First(&key, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context, owner APIKeyOwner) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := owner.scope(r.db.WithContext(ctx)).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepository) Update(ctx context.Context, key *models.APIKey, columns ...string) error {
+	return r.db.WithContext(ctx).Model(key).Select(columns).Updates(key).Error
+}
+
+func (r *apiKeyRepository) Delete(ctx context.Context, owner APIKeyOwner, id uint) error {
+	result := owner.scope(r.db.WithContext(ctx)).Delete(&models.APIKey{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) Touch(ctx context.Context, id uint, now, since time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, since).
+		UpdateColumn("last_used_at", now).Error
+}
@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// AuditRepository appends to the audit trail
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
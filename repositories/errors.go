@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Driver codes for unique index violations
+const (
+	mysqlDuplicateEntry   = 1062
+	postgresUniqueViolate = "23505"
+	sqliteConstraintUniq  = 2067
+)
+
+var (
+	// ErrNotFound is returned when a lookup matches no record
+	ErrNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when a conditional write finds the
+	// record at a different version than expected
+	ErrVersionConflict = errors.New("record version has changed")
+)
+
+// DuplicateError reports a write rejected by a unique index. Column is the
+// indexed column when it could be identified, and empty otherwise.
+type DuplicateError struct {
+	Column string
+	Err    error
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Column == "" {
+		return "duplicate value for a unique index"
+	}
+	return fmt.Sprintf("duplicate value for unique column %q", e.Column)
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return e.Err
+}
+
+// translateError maps GORM and driver errors to repository errors. A unique
+// index violation is attributed to the first of uniqueColumns named by the
+// violated index.
+func translateError(err error, uniqueColumns ...string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if index, ok := uniqueViolation(err); ok {
+		dup := &DuplicateError{Err: err}
+		for _, column := range uniqueColumns {
+			if strings.Contains(index, column) {
+				dup.Column = column
+				break
+			}
+		}
+		return dup
+	}
+	return err
+}
+
+// uniqueViolation reports whether err is a unique index violation on any
+// supported driver, along with the driver's name for the violated index
+func uniqueViolation(err error) (string, bool) {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		// Duplicate entry 'x' for key 'users.idx_users_email'
+		_, key, _ := strings.Cut(mysqlErr.Message, "for key ")
+		return key, true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolate {
+		return pgErr.ConstraintName, true
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqliteConstraintUniq {
+		// UNIQUE constraint failed: users.email
+		_, columns, _ := strings.Cut(sqliteErr.Error(), "UNIQUE constraint failed: ")
+		return columns, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+)
+
+// memorySweepInterval is how many recorded failures pass between sweeps of
+// stale counters, which keeps the map bounded under a spray of usernames
+const memorySweepInterval = 1024
+
+type memoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+	writes   int
+}
+
+// NewMemoryLoginAttemptRepository keeps counters in the process. They are
+// lost on restart and not shared between replicas.
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &memoryLoginAttemptRepository{attempts: make(map[string]*models.LoginAttempt)}
+}
+
+func (r *memoryLoginAttemptRepository) Find(_ context.Context, identifier string) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[identifier]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *attempt
+	return &found, nil
+}
+
+func (r *memoryLoginAttemptRepository) RecordFailure(_ context.Context, identifier string, now time.Time,
+	window time.Duration) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes++
+	if r.writes%memorySweepInterval == 0 {
+		r.sweep(now, now.Add(-window))
+	}
+
+	attempt, ok := r.attempts[identifier]
+	switch {
+	case !ok:
+		attempt = &models.LoginAttempt{Identifier: identifier}
+		r.attempts[identifier] = attempt
+	case attempt.LockedUntil != nil && !now.Before(*attempt.LockedUntil):
+		attempt.Failures = 0
+		attempt.LockedUntil = nil
+	case attempt.LockedUntil == nil && attempt.LastFailedAt.Before(now.Add(-window)):
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = now
+
+	recorded := *attempt
+	return &recorded, nil
+}
+
+func (r *memoryLoginAttemptRepository) Lock(_ context.Context, identifier string, now, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[identifier]
+	if !ok || attempt.IsLocked(now) {
+		return false, nil
+	}
+	attempt.LockedUntil = &until
+	return true, nil
+}
+
+func (r *memoryLoginAttemptRepository) Delete(_ context.Context, identifier string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, identifier)
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) DeleteStale(_ context.Context, now, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sweep(now, before), nil
+}
+
+// sweep removes unlocked counters last updated before before. The caller
+// holds the lock.
+func (r *memoryLoginAttemptRepository) sweep(now, before time.Time) int64 {
+	var removed int64
+	for identifier, attempt := range r.attempts {
+		if attempt.LastFailedAt.Before(before) && !attempt.IsLocked(now) {
+			delete(r.attempts, identifier)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptRepository keeps failed login counters. The database
+// implementation is shared by every replica; the in-memory one from
+// NewMemoryLoginAttemptRepository only sees its own process.
+type LoginAttemptRepository interface {
+	// Find returns the counter for identifier, or ErrNotFound
+	Find(ctx context.Context, identifier string) (*models.LoginAttempt, error)
+	// RecordFailure counts a failure at now and returns the updated counter.
+	// The count starts over when the previous failure is older than window
+	// or an earlier lockout has expired.
+	RecordFailure(ctx context.Context, identifier string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+	// Lock refuses attempts until until, and reports false when a lockout
+	// was already in force
+	Lock(ctx context.Context, identifier string, now, until time.Time) (bool, error)
+	Delete(ctx context.Context, identifier string) error
+	// DeleteStale removes unlocked counters whose last failure is before
+	// before, and returns how many were removed
+	DeleteStale(ctx context.Context, now, before time.Time) (int64, error)
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db}
+}
+
+func (r *loginAttemptRepository) Find(ctx context.Context, identifier string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.WithContext(ctx).Where("identifier = ?", identifier).First(&attempt).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) RecordFailure(ctx context.Context, identifier string, now time.Time,
+	window time.Duration) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{Identifier: identifier, LastFailedAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		// A single conditional update, so that concurrent failures on
+		// other replicas are all counted
+		err = tx.Model(&models.LoginAttempt{}).
+			Where("identifier = ?", identifier).
+			Updates(map[string]any{
+				"failures": gorm.Expr("CASE WHEN last_failed_at < ? OR locked_until <= ? THEN 1 ELSE failures + 1 END",
+					now.Add(-window), now),
+				"last_failed_at": now,
+				"locked_until":   gorm.Expr("CASE WHEN locked_until <= ? THEN NULL ELSE locked_until END", now),
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("identifier = ?", identifier).First(&attempt).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &attempt, nil
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, identifier string, now, until time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.LoginAttempt{}).
+		Where("identifier = ? AND (locked_until IS NULL OR locked_until <= ?)", identifier, now).
+		UpdateColumn("locked_until", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *loginAttemptRepository) Delete(ctx context.Context, identifier string) error {
+	return r.db.WithContext(ctx).Where("identifier = ?", identifier).Delete(&models.LoginAttempt{}).Error
+}
+
+func (r *loginAttemptRepository) DeleteStale(ctx context.Context, now, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until <= ?)", before, now).
+		Delete(&models.LoginAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// ErrMFAStateChanged is returned when the user's MFA state changed between
+// reading it and writing it, such as a second confirmation of one enrolment
+var ErrMFAStateChanged = errors.New("mfa state changed")
+
+// MFARepository stores TOTP secrets and recovery codes
+type MFARepository interface {
+	SavePendingSecret(ctx context.Context, userID uint, encryptedSecret string) error
+	Enable(ctx context.Context, userID uint, encryptedSecret string, step int64, codeHashes []string) error
+	Disable(ctx context.Context, userID uint) error
+	UseStep(ctx context.Context, userID uint, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+	SetChallenge(ctx context.Context, userID uint, challengeHash string) error
+	UseChallenge(ctx context.Context, userID uint, challengeHash string) (bool, error)
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+// SavePendingSecret stores a new secret for a user who has not enabled MFA,
+// replacing any earlier unconfirmed enrolment
+func (r *mfaRepository) SavePendingSecret(ctx context.Context, userID uint, encryptedSecret string) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND mfa_enabled_at IS NULL", userID).
+		UpdateColumn("mfa_secret", encryptedSecret)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAStateChanged
+	}
+	return nil
+}
+
+// Enable turns MFA on for the pending secret and replaces the recovery
+// codes. step is the time step of the code that confirmed the enrolment,
+// which is thereby used up.
+func (r *mfaRepository) Enable(ctx context.Context, userID uint, encryptedSecret string, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND mfa_enabled_at IS NULL AND mfa_secret = ?", userID, encryptedSecret).
+			Updates(map[string]any{
+				"mfa_enabled_at": time.Now(),
+				"mfa_last_step":  step,
+				"version":        gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAStateChanged
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// Disable removes the secret and the recovery codes
+func (r *mfaRepository) Disable(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND mfa_enabled_at IS NOT NULL", userID).
+			Updates(map[string]any{
+				"mfa_secret":     "",
+				"mfa_enabled_at": nil,
+				"mfa_last_step":  0,
+				"version":        gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAStateChanged
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// UseStep records step as the last accepted TOTP step. It reports false
+// when that step or a later one was already used.
+func (r *mfaRepository) UseStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND mfa_enabled_at IS NOT NULL AND mfa_last_step < ?", userID, step).
+		UpdateColumn("mfa_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseRecoveryCode marks the user's unused code with the given hash as used.
+// It reports false when there is no such code.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (r *mfaRepository) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// SetChallenge stores the hash of a new login challenge, replacing the
+// previous one
+func (r *mfaRepository) SetChallenge(ctx context.Context, userID uint, challengeHash string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("mfa_challenge", challengeHash).Error
+}
+
+// UseChallenge clears the login challenge with the given hash. It reports
+// false when that challenge is not the current one or was already used.
+func (r *mfaRepository) UseChallenge(ctx context.Context, userID uint, challengeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND mfa_challenge = ? AND mfa_challenge <> ''", userID, challengeHash).
+		UpdateColumn("mfa_challenge", "")
+	return result.RowsAffected > 0, result.Error
+}
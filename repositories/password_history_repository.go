@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// PasswordHistoryRepository stores the hashes of users' earlier passwords
+type PasswordHistoryRepository interface {
+	// Recent returns up to limit hashes, newest first
+	Recent(ctx context.Context, userID uint, limit int) ([]string, error)
+	// Add records hash and drops all but the keep newest entries
+	Add(ctx context.Context, userID uint, hash string, keep int) error
+}
+
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+func (r *passwordHistoryRepository) Recent(ctx context.Context, userID uint, limit int) ([]string, error) {
+	var hashes []string
+	err := r.db.WithContext(ctx).Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("password_hash", &hashes).Error
+	return hashes, err
+}
+
+func (r *passwordHistoryRepository) Add(ctx context.Context, userID uint, hash string, keep int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		entry := &models.PasswordHistory{UserID: userID, PasswordHash: hash}
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+
+		var kept []uint
+		err := tx.Model(&models.PasswordHistory{}).
+			Where("user_id = ?", userID).
+			Order("id DESC").
+			Limit(keep).
+			Pluck("id", &kept).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND id NOT IN ?", userID, kept).Delete(&models.PasswordHistory{}).Error
+	})
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// ErrTokenUsed is returned when a reset token was used or expired before it
+// could be redeemed
+var ErrTokenUsed = errors.New("token already used")
+
+// PasswordResetRepository provides persistence for password reset tokens
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	FindByHash(ctx context.Context, hash string) (*models.PasswordResetToken, error)
+	Redeem(ctx context.Context, token *models.PasswordResetToken, passwordHash string) error
+}
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create stores token and discards the user's earlier unused tokens, so
+// that only the most recent reset link works
+func (r *passwordResetRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND used_at IS NULL", token.UserID).
+			Delete(&models.PasswordResetToken{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *passwordResetRepository) FindByHash(ctx context.Context, hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+// Redeem marks token as used, sets the user's password and revokes their
+// refresh tokens in one transaction. Marking the token is conditional so a
+// token can be redeemed only once, even by concurrent requests.
+func (r *passwordResetRepository) Redeem(ctx context.Context, token *models.PasswordResetToken, passwordHash string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenUsed
+		}
+
+		result = tx.Model(&models.User{}).
+			Where("id = ?", token.UserID).
+			Updates(map[string]any{
+				"password": passwordHash,
+				"version":  gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", token.UserID).
+			Update("revoked_at", now).Error
+	})
+}
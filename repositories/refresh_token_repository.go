@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// ErrTokenRevoked is returned when a token was revoked before it could be rotated
+var ErrTokenRevoked = errors.New("token already revoked")
+
+// RefreshTokenRepository provides persistence for refresh tokens
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	Rotate(ctx context.Context, current *models.RefreshToken, replacement *models.RefreshToken) error
+	RevokeByHash(ctx context.Context, hash string) error
+	RevokeAllForUser(ctx context.Context, userID uint) error
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *refreshTokenRepository) FindByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+// Rotate revokes the current token and stores its replacement atomically.
+// The revocation is conditional so that two concurrent rotations of the
+// same token cannot both succeed.
+func (r *refreshTokenRepository) Rotate(ctx context.Context, current *models.RefreshToken, replacement *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenRevoked
+		}
+		return tx.Create(replacement).Error
+	})
+}
+
+func (r *refreshTokenRepository) RevokeByHash(ctx context.Context, hash string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hash).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// RoleRepository provides access to roles and the permissions they grant
+type RoleRepository interface {
+	PermissionsForUser(ctx context.Context, userID uint) ([]string, error)
+	RolesForUser(ctx context.Context, userID uint) ([]string, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// PermissionsForUser returns the distinct permission names granted to the
+// user through all of their roles. A soft-deleted user keeps its role
+// assignments until it is purged but holds no permissions.
+func (r *roleRepository) PermissionsForUser(ctx context.Context, userID uint) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).
+		Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Where("user_roles.user_id = ? AND users.deleted_at IS NULL", userID).
+		Distinct().
+		Pluck("permissions.name", &names).Error
+	return names, err
+}
+
+// RolesForUser returns the names of the roles assigned to the user
+func (r *roleRepository) RolesForUser(ctx context.Context, userID uint) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).
+		Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("roles.name", &names).Error
+	return names, err
+}
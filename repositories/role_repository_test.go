@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/canhbk/golang-gin-starter-kit/internal/testutil"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+)
+
+func TestPermissionsForUserSkipsDeletedUsers(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	users := NewUserRepository(db)
+	roles := NewRoleRepository(db)
+
+	role := models.Role{Name: "support", Permissions: []models.Permission{
+		{Name: models.PermissionUsersRead},
+		{Name: models.PermissionUsersList},
+	}}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash", Roles: []models.Role{role}}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	names, err := roles.PermissionsForUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("permissions: %v", err)
+	}
+	slices.Sort(names)
+	if want := []string{models.PermissionUsersList, models.PermissionUsersRead}; !slices.Equal(names, want) {
+		t.Fatalf("permissions = %v, want %v", names, want)
+	}
+
+	if err := users.Delete(ctx, user.ID, 0); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	names, err = roles.PermissionsForUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("permissions: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("permissions of a deleted user = %v, want none", names)
+	}
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+)
+
+// serviceAccountUniqueColumns are the columns reported by DuplicateError
+var serviceAccountUniqueColumns = []string{"name"}
+
+// ServiceAccountRepository provides persistence for service accounts
+type ServiceAccountRepository interface {
+	Create(ctx context.Context, account *models.ServiceAccount) error
+	FindByID(ctx context.Context, id uint) (*models.ServiceAccount, error)
+	// List returns every service account ordered by name
+	List(ctx context.Context) ([]models.ServiceAccount, error)
+	Update(ctx context.Context, account *models.ServiceAccount, columns ...string) error
+	// Delete removes the account together with its API keys
+	Delete(ctx context.Context, id uint) error
+}
+
+type serviceAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+func (r *serviceAccountRepository) Create(ctx context.Context, account *models.ServiceAccount) error {
+	return translateError(r.db.WithContext(ctx).Create(account).Error, serviceAccountUniqueColumns...)
+}
+
+func (r *serviceAccountRepository) FindByID(ctx context.Context, id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := r.db.WithContext(ctx).First(&account, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &account, nil
+}
+
+func (r *serviceAccountRepository) List(ctx context.Context) ([]models.ServiceAccount, error) {
+	accounts := []models.ServiceAccount{}
+	err := r.db.WithContext(ctx).Order("name").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *serviceAccountRepository) Update(ctx context.Context, account *models.ServiceAccount, columns ...string) error {
+	err := r.db.WithContext(ctx).Model(account).Select(columns).Updates(account).Error
+	return translateError(err, serviceAccountUniqueColumns...)
+}
+
+// Delete removes the keys explicitly, since SQLite only honours the
+// cascade when foreign keys are enabled on the connection
+func (r *serviceAccountRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_account_id = ?", id).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.ServiceAccount{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
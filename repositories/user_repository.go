@@ -0,0 +1,454 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// userUniqueColumns are the users columns backed by a unique index. The
+// indexes only cover users that are not deleted.
+var userUniqueColumns = []string{"username", "email"}
+
+// UserRepository provides persistence for users
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	FindByUsernameOrEmail(ctx context.Context, login string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	List(ctx context.Context, filter UserFilter, order []OrderBy, offset, limit int) ([]models.User, error)
+	ListAfter(ctx context.Context, filter UserFilter, after *UserKey, desc bool, limit int) ([]models.User, error)
+	Count(ctx context.Context, filter UserFilter) (int64, error)
+	Update(ctx context.Context, user *models.User, columns ...string) error
+	// UpdateAndRevokeSessions is Update plus the revocation of every refresh
+	// token of the user, in one transaction
+	UpdateAndRevokeSessions(ctx context.Context, user *models.User, columns ...string) error
+	Delete(ctx context.Context, id uint, version uint) error
+	ExistsByUsername(ctx context.Context, username string) (bool, error)
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	ListDeleted(ctx context.Context, offset, limit int) ([]models.User, int64, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	CreateBatch(ctx context.Context, users []*models.User) error
+	FindTaken(ctx context.Context, usernames, emails []string) (takenUsernames, takenEmails map[string]bool, err error)
+	Each(ctx context.Context, batchSize int, fn func(users []models.User) error) error
+	MarkVerificationSent(ctx context.Context, id uint, now, notBefore time.Time) (bool, error)
+	MarkEmailVerified(ctx context.Context, id uint, email string, at time.Time) (bool, error)
+	IsEmailVerified(ctx context.Context, id uint) (bool, error)
+	ReplacePasswordHash(ctx context.Context, id uint, oldHash, newHash string) (bool, error)
+}
+
+// purgeBatchSize bounds how many users PurgeDeletedBefore removes per transaction
+const purgeBatchSize = 500
+
+// UserFilter narrows a user listing. Zero values match everything.
+type UserFilter struct {
+	// Search matches a case-insensitive substring of the username or email
+	Search        string
+	Email         string
+	CreatedAfter  *time.Time // inclusive
+	CreatedBefore *time.Time // exclusive
+}
+
+// OrderBy sorts a listing by a column. Column must come from a fixed
+// whitelist, never straight from user input.
+type OrderBy struct {
+	Column string
+	Desc   bool
+}
+
+// UserKey is a position in the (created_at, id) keyset ordering
+type UserKey struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error, userUniqueColumns...)
+}
+
+func (r *userRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByUsernameOrEmail(ctx context.Context, login string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).
+		Where("username = ? OR email = ?", login, login).
+		First(&user).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *userRepository) List(ctx context.Context, filter UserFilter, order []OrderBy, offset, limit int) ([]models.User, error) {
+	db := applyUserFilter(r.db.WithContext(ctx), filter)
+	for _, o := range order {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: o.Column}, Desc: o.Desc})
+	}
+	// Break ties on the primary key so that pages are stable
+	db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+
+	var users []models.User
+	if err := db.Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ListAfter returns up to limit users following the after key in
+// (created_at, id) order, or the first ones when after is nil. Unlike
+// offset paging, rows inserted meanwhile cannot shift or repeat a page.
+func (r *userRepository) ListAfter(ctx context.Context, filter UserFilter, after *UserKey, desc bool, limit int) ([]models.User, error) {
+	db := applyUserFilter(r.db.WithContext(ctx), filter)
+	if after != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		db = db.Where("created_at "+op+" ? OR (created_at = ? AND id "+op+" ?)",
+			after.CreatedAt, after.CreatedAt, after.ID)
+	}
+
+	var users []models.User
+	err := db.
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: desc}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: desc}).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) Count(ctx context.Context, filter UserFilter) (int64, error) {
+	var total int64
+	err := applyUserFilter(r.db.WithContext(ctx).Model(&models.User{}), filter).Count(&total).Error
+	return total, err
+}
+
+func applyUserFilter(db *gorm.DB, filter UserFilter) *gorm.DB {
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(filter.Search)) + "%"
+		db = db.Where("LOWER(username) LIKE ? ESCAPE '!' OR LOWER(email) LIKE ? ESCAPE '!'", pattern, pattern)
+	}
+	if filter.Email != "" {
+		db = db.Where("email = ?", filter.Email)
+	}
+	if filter.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	return db
+}
+
+// escapeLike escapes LIKE wildcards using '!', which needs no extra quoting
+// on any supported driver
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// Update writes only the given columns of user, plus updated_at, and bumps
+// its version. The write is conditional on the version user was read at, so
+// it fails with ErrVersionConflict when another write got there first.
+func (r *userRepository) Update(ctx context.Context, user *models.User, columns ...string) error {
+	return updateUser(r.db.WithContext(ctx), user, columns)
+}
+
+func (r *userRepository) UpdateAndRevokeSessions(ctx context.Context, user *models.User, columns ...string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateUser(tx, user, columns); err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// updateUser performs the versioned write behind Update and restores the
+// version of user when it fails
+func updateUser(db *gorm.DB, user *models.User, columns []string) error {
+	readVersion := user.Version
+	user.Version++
+
+	result := db.Model(user).
+		Where("version = ?", readVersion).
+		Select(append(slices.Clip(columns), "version")).
+		Updates(user)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		user.Version = readVersion
+		return translateError(result.Error, userUniqueColumns...)
+	}
+	return nil
+}
+
+// Delete soft-deletes a user. A non-zero version makes the delete
+// conditional on the user still being at that version.
+func (r *userRepository) Delete(ctx context.Context, id uint, version uint) error {
+	db := r.db.WithContext(ctx)
+	if version != 0 {
+		db = db.Where("version = ?", version)
+	}
+	result := db.Delete(&models.User{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	if version == 0 {
+		return ErrNotFound
+	}
+
+	// Tell a missing user apart from one that has moved on
+	if _, err := r.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+func (r *userRepository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	return r.exists(ctx, "username = ?", username)
+}
+
+func (r *userRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	return r.exists(ctx, "email = ?", email)
+}
+
+// exists checks for a matching user that is not deleted, since the unique
+// indexes only cover those
+func (r *userRepository) exists(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where(query, args...).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepository) ListDeleted(ctx context.Context, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	db := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL")
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("deleted_at DESC").Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// Restore clears deleted_at on a soft-deleted user. It fails with a
+// DuplicateError when another user has taken its username or email since.
+func (r *userRepository) Restore(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().
+		Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return translateError(result.Error, userUniqueColumns...)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Purge permanently removes a soft-deleted user along with its refresh
+// tokens and role assignments
+func (r *userRepository) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		purged, err := purgeUsers(tx, []uint{id})
+		if err != nil {
+			return err
+		}
+		if purged == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// PurgeDeletedBefore permanently removes users soft-deleted before cutoff,
+// in batches so that no single transaction grows unbounded
+func (r *userRepository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		var ids []uint
+		err := r.db.WithContext(ctx).Unscoped().
+			Model(&models.User{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Order("id").
+			Limit(purgeBatchSize).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			purged, err := purgeUsers(tx, ids)
+			total += purged
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// purgeUsers hard-deletes the soft-deleted users among ids and the rows that
+// reference them, returning how many users were removed
+func purgeUsers(tx *gorm.DB, ids []uint) (int64, error) {
+	var deleted []uint
+	err := tx.Unscoped().Model(&models.User{}).
+		Where("id IN ? AND deleted_at IS NOT NULL", ids).
+		Pluck("id", &deleted).Error
+	if err != nil || len(deleted) == 0 {
+		return 0, err
+	}
+
+	if err := tx.Where("user_id IN ?", deleted).Delete(&models.RefreshToken{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("user_id IN ?", deleted).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("user_id IN ?", deleted).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("user_id IN ?", deleted).Delete(&models.PasswordHistory{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("user_id IN ?", deleted).Delete(&models.APIKey{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Exec("DELETE FROM user_roles WHERE user_id IN ?", deleted).Error; err != nil {
+		return 0, err
+	}
+	result := tx.Unscoped().Where("id IN ?", deleted).Delete(&models.User{})
+	return result.RowsAffected, result.Error
+}
+
+// CreateBatch inserts users in a single transaction, so that either all of
+// them are created or none is
+func (r *userRepository) CreateBatch(ctx context.Context, users []*models.User) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(users).Error
+	})
+	return translateError(err, userUniqueColumns...)
+}
+
+// FindTaken reports which of the usernames and emails already belong to a
+// user that is not deleted
+func (r *userRepository) FindTaken(ctx context.Context, usernames, emails []string) (map[string]bool, map[string]bool, error) {
+	var existing []models.User
+	err := r.db.WithContext(ctx).
+		Select("username", "email").
+		Where("username IN ? OR email IN ?", usernames, emails).
+		Find(&existing).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	takenUsernames := make(map[string]bool)
+	takenEmails := make(map[string]bool)
+	for _, user := range existing {
+		takenUsernames[user.Username] = true
+		takenEmails[user.Email] = true
+	}
+	return takenUsernames, takenEmails, nil
+}
+
+// Each walks all users in primary key order, batchSize rows at a time, so
+// that callers can stream large tables with bounded memory
+func (r *userRepository) Each(ctx context.Context, batchSize int, fn func(users []models.User) error) error {
+	var batch []models.User
+	return r.db.WithContext(ctx).FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// MarkVerificationSent records that a verification email goes out at now.
+// It reports false, without writing, when the address is already verified
+// or the previous email was sent after notBefore. The check and the write
+// are one statement, so concurrent resends cannot both pass.
+func (r *userRepository) MarkVerificationSent(ctx context.Context, id uint, now, notBefore time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Where("verification_sent_at IS NULL OR verification_sent_at <= ?", notBefore).
+		UpdateColumn("verification_sent_at", now)
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkEmailVerified confirms email for the user. It reports false when the
+// user no longer has that address or has already verified it.
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id uint, email string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", id, email).
+		Updates(map[string]any{
+			"email_verified_at": at,
+			"version":           gorm.Expr("version + 1"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *userRepository) IsEmailVerified(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NOT NULL", id).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ReplacePasswordHash swaps the hash of an unchanged password for one made
+// with current settings. It reports false when the password has changed
+// since oldHash was read. The version is kept, as the user's data is the same.
+func (r *userRepository) ReplacePasswordHash(ctx context.Context, id uint, oldHash, newHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND password = ?", id, oldHash).
+		UpdateColumn("password", newHash)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/internal/testutil"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+)
+
+func createTestUser(t *testing.T, users UserRepository, name string) *models.User {
+	t.Helper()
+	user := &models.User{Username: name, Email: name + "@example.com", Password: "hash"}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestUserUpdateIsConditionalOnVersion(t *testing.T) {
+	ctx := context.Background()
+	users := NewUserRepository(testutil.NewDB(t))
+	created := createTestUser(t, users, "alice")
+
+	first, err := users.FindByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	second, err := users.FindByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+
+	first.Username = "alice2"
+	if err := users.Update(ctx, first, "username"); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if first.Version != created.Version+1 {
+		t.Errorf("version after update = %d, want %d", first.Version, created.Version+1)
+	}
+
+	second.Email = "other@example.com"
+	if err := users.Update(ctx, second, "email"); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update error = %v, want ErrVersionConflict", err)
+	}
+	if second.Version != created.Version {
+		t.Errorf("version after failed update = %d, want it restored to %d", second.Version, created.Version)
+	}
+
+	stored, err := users.FindByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	if stored.Username != "alice2" || stored.Email != created.Email || stored.Version != first.Version {
+		t.Errorf("stored user = %s %s v%d, want the first update only", stored.Username, stored.Email, stored.Version)
+	}
+}
+
+func TestUserUpdateAndRevokeSessionsRollsBackOnConflict(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	users := NewUserRepository(db)
+	tokens := NewRefreshTokenRepository(db)
+	user := createTestUser(t, users, "bob")
+
+	token := &models.RefreshToken{UserID: user.ID, TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := tokens.Create(ctx, token); err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	stale := *user
+	stale.Version--
+	stale.Password = "new-hash"
+	if err := users.UpdateAndRevokeSessions(ctx, &stale, "password"); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale update error = %v, want ErrVersionConflict", err)
+	}
+	if stored, _ := tokens.FindByHash(ctx, "hash"); stored == nil || stored.RevokedAt != nil {
+		t.Fatal("refresh token was revoked by a failed update")
+	}
+
+	user.Password = "new-hash"
+	if err := users.UpdateAndRevokeSessions(ctx, user, "password"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if stored, _ := tokens.FindByHash(ctx, "hash"); stored == nil || stored.RevokedAt == nil {
+		t.Error("refresh token is still active after the password changed")
+	}
+}
+
+func TestUserDeleteIsConditionalOnVersion(t *testing.T) {
+	ctx := context.Background()
+	users := NewUserRepository(testutil.NewDB(t))
+	user := createTestUser(t, users, "carol")
+
+	if err := users.Delete(ctx, user.ID, user.Version+1); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("stale delete error = %v, want ErrVersionConflict", err)
+	}
+	if err := users.Delete(ctx, user.ID+1, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete of a missing user error = %v, want ErrNotFound", err)
+	}
+	if err := users.Delete(ctx, user.ID, user.Version); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := users.FindByID(ctx, user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("find after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeletedUserReleasesItsNames(t *testing.T) {
+	ctx := context.Background()
+	users := NewUserRepository(testutil.NewDB(t))
+	deleted := createTestUser(t, users, "dave")
+	if err := users.Delete(ctx, deleted.ID, deleted.Version); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if taken, err := users.ExistsByUsername(ctx, "dave"); err != nil || taken {
+		t.Fatalf("ExistsByUsername = %t, %v, want false for a deleted user", taken, err)
+	}
+	reused := &models.User{Username: "dave", Email: "david@example.com", Password: "hash"}
+	if err := users.Create(ctx, reused); err != nil {
+		t.Fatalf("create user with the released username: %v", err)
+	}
+
+	var dup *DuplicateError
+	if err := users.Restore(ctx, deleted.ID); !errors.As(err, &dup) || dup.Column != "username" {
+		t.Fatalf("restore error = %v, want a duplicate username", err)
+	}
+
+	if err := users.Delete(ctx, reused.ID, reused.Version); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := users.Restore(ctx, deleted.ID); err != nil {
+		t.Fatalf("restore once the name is free again: %v", err)
+	}
+}
@@ -1,36 +1,162 @@
 package routes
 
 import (
+	"github.com/canhbk/golang-gin-starter-kit/config"
 	"github.com/canhbk/golang-gin-starter-kit/controllers"
 	v1 "github.com/canhbk/golang-gin-starter-kit/controllers/v1"
+	"github.com/canhbk/golang-gin-starter-kit/mail"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/services"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func InitializeRoutes(r *gin.Engine) {
+// InitializeRoutes registers every route. Work that handlers leave running in
+// the background, such as sending mail, is started on background.
+func InitializeRoutes(r *gin.Engine, db *gorm.DB, cfg *config.Config, mailer mail.Sender,
+	background *services.Background) error {
 	// API Version 1 Routes
 	v1Routes := r.Group("/api/v1")
-	initializeV1Routes(v1Routes)
+	if err := initializeV1Routes(v1Routes, db, cfg, mailer, background); err != nil {
+		return err
+	}
+
+	// Health check routes (unversioned)
+	healthRegistry := services.NewHealthRegistry(cfg.Server.HealthCheckTimeout)
+	healthRegistry.Register("database", true, services.NewDatabaseChecker(db))
 
-	// Health check route (unversioned)
-	healthController := controllers.NewHealthController()
+	healthController := controllers.NewHealthController(healthRegistry)
 	r.GET("/health", healthController.HealthCheck)
+	r.GET("/health/live", healthController.Liveness)
+	r.GET("/health/ready", healthController.Readiness)
+
+	// Unknown paths get the same problem document as every other error
+	r.NoRoute(middleware.NoRoute())
+	return nil
 }
 
-func initializeV1Routes(rg *gin.RouterGroup) {
+func initializeV1Routes(rg *gin.RouterGroup, db *gorm.DB, cfg *config.Config, mailer mail.Sender,
+	background *services.Background) error {
+	// Initialize repositories
+	userRepository := repositories.NewUserRepository(db)
+	roleRepository := repositories.NewRoleRepository(db)
+	refreshTokenRepository := repositories.NewRefreshTokenRepository(db)
+	passwordResetRepository := repositories.NewPasswordResetRepository(db)
+	mfaRepository := repositories.NewMFARepository(db)
+	auditRepository := repositories.NewAuditRepository(db)
+	passwordHistoryRepository := repositories.NewPasswordHistoryRepository(db)
+	apiKeyRepository := repositories.NewAPIKeyRepository(db)
+	serviceAccountRepository := repositories.NewServiceAccountRepository(db)
+	loginAttemptRepository := repositories.NewLoginAttemptRepository(db)
+	if cfg.Security.Lockout.Store == config.LockoutStoreMemory {
+		loginAttemptRepository = repositories.NewMemoryLoginAttemptRepository()
+	}
+
+	// Initialize services
+	passwordService, err := services.NewPasswordService(userRepository, passwordHistoryRepository,
+		cfg.Security.Password)
+	if err != nil {
+		return err
+	}
+	lockoutService := services.NewLockoutService(loginAttemptRepository, auditRepository, userRepository,
+		cfg.Security.Lockout)
+	authService := services.NewAuthService(userRepository, refreshTokenRepository, mfaRepository, passwordService,
+		lockoutService, cfg.Security.MFA.ChallengeTTL)
+	emailVerificationService := services.NewEmailVerificationService(userRepository, mailer, background,
+		cfg.Security.EmailVerification)
+	userService := services.NewUserService(userRepository, passwordService, emailVerificationService)
+	mfaService, err := services.NewMFAService(userRepository, mfaRepository, refreshTokenRepository, passwordService,
+		lockoutService, cfg.Security.MFA)
+	if err != nil {
+		return err
+	}
+	passwordResetService := services.NewPasswordResetService(userRepository, passwordResetRepository,
+		passwordService, lockoutService, mailer, background, cfg.Security.PasswordReset)
+	permissionService := services.NewPermissionService(roleRepository, userRepository,
+		cfg.Security.MFA.RequiredRoleList())
+	apiKeyService := services.NewAPIKeyService(apiKeyRepository, serviceAccountRepository, userRepository,
+		permissionService, cfg.Security.APIKeys)
+	serviceAccountService := services.NewServiceAccountService(serviceAccountRepository)
+
 	// Initialize V1 controllers
-	userController := v1.NewUserController()
+	authController := v1.NewAuthController(authService, passwordResetService, emailVerificationService)
+	userController := v1.NewUserController(userService)
+	mfaController := v1.NewMFAController(mfaService)
+	lockoutController := v1.NewLockoutController(lockoutService)
+	userAPIKeyController := v1.NewUserAPIKeyController(apiKeyService)
+	serviceAccountController := v1.NewServiceAccountController(serviceAccountService)
+	serviceAccountAPIKeyController := v1.NewServiceAccountAPIKeyController(apiKeyService)
+
+	authorizer := middleware.NewAuthorizer(permissionService)
+	verified := middleware.RequireVerifiedEmail(emailVerificationService)
+
+	// Auth routes
+	auth := rg.Group("/auth")
+	{
+		auth.POST("/login", authController.Login)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", authController.Logout)
+		auth.POST("/password/forgot", authController.ForgotPassword)
+		auth.POST("/password/reset", authController.ResetPassword)
+		auth.GET("/verify", authController.VerifyEmail)
+		auth.POST("/verify/resend", middleware.AuthRequired(), authController.ResendVerification)
+		auth.POST("/mfa/verify", mfaController.Verify)
+		auth.GET("/mfa", middleware.AuthRequired(), mfaController.Status)
+		auth.POST("/mfa/enroll", middleware.AuthRequired(), mfaController.Enroll)
+		auth.POST("/mfa/confirm", middleware.AuthRequired(), mfaController.Confirm)
+		auth.POST("/mfa/disable", middleware.AuthRequired(), mfaController.Disable)
+	}
+
+	// User routes, which batch jobs may also call with an API key. Acting on
+	// other accounts needs a verified email; reading and updating one's own
+	// account does not, so that a mistyped address can be corrected.
+	users := rg.Group("/users", middleware.AuthRequiredOrAPIKey(apiKeyService))
+	{
+		users.POST("", verified, authorizer.RequirePermission(models.PermissionUsersCreate), userController.Create)
+		users.GET("", verified, authorizer.RequirePermission(models.PermissionUsersList), userController.List)
+		users.POST("/import", verified, authorizer.RequirePermission(models.PermissionUsersCreate), userController.Import)
+		users.GET("/export", verified, authorizer.RequirePermission(models.PermissionUsersList), userController.Export)
+		users.GET("/deleted", verified, authorizer.RequirePermission(models.PermissionUsersRestore), userController.ListDeleted)
+		users.GET("/:id", authorizer.RequireSelfOrPermission("id", models.PermissionUsersRead), userController.Get)
+		users.PUT("/:id", authorizer.RequireSelfOrPermission("id", models.PermissionUsersUpdate), userController.Update)
+		users.PATCH("/:id", authorizer.RequireSelfOrPermission("id", models.PermissionUsersUpdate), userController.Patch)
+		users.DELETE("/:id", verified, authorizer.RequirePermission(models.PermissionUsersDelete), userController.Delete)
+		users.POST("/:id/restore", verified, authorizer.RequirePermission(models.PermissionUsersRestore), userController.Restore)
+		users.DELETE("/:id/permanent", verified, authorizer.RequirePermission(models.PermissionUsersPurge), userController.Purge)
+		users.POST("/:id/unlock", verified, authorizer.RequirePermission(models.PermissionUsersUnlock), lockoutController.Unlock)
+	}
+
+	// API key routes need a login, so that a key cannot be used to issue
+	// further keys
+	userKeys := rg.Group("/users/:id/api-keys", middleware.AuthRequired(), verified,
+		authorizer.RequireSelfOrPermission("id", models.PermissionAPIKeysManage))
+	{
+		userKeys.POST("", userAPIKeyController.Create)
+		userKeys.GET("", userAPIKeyController.List)
+		userKeys.GET("/:keyID", userAPIKeyController.Get)
+		userKeys.PATCH("/:keyID", userAPIKeyController.Patch)
+		userKeys.DELETE("/:keyID", userAPIKeyController.Delete)
+	}
 
-	// User routes
-	users := rg.Group("/users")
+	serviceAccounts := rg.Group("/service-accounts", middleware.AuthRequired(), verified,
+		authorizer.RequirePermission(models.PermissionServiceAccountsManage))
 	{
-		users.POST("", userController.Create)
-		users.GET("", userController.List)
-		users.GET("/:id", userController.Get)
-		users.PUT("/:id", userController.Update)
-		users.DELETE("/:id", userController.Delete)
+		serviceAccounts.POST("", serviceAccountController.Create)
+		serviceAccounts.GET("", serviceAccountController.List)
+		serviceAccounts.GET("/:id", serviceAccountController.Get)
+		serviceAccounts.PATCH("/:id", serviceAccountController.Patch)
+		serviceAccounts.DELETE("/:id", serviceAccountController.Delete)
+		serviceAccounts.POST("/:id/api-keys", serviceAccountAPIKeyController.Create)
+		serviceAccounts.GET("/:id/api-keys", serviceAccountAPIKeyController.List)
+		serviceAccounts.GET("/:id/api-keys/:keyID", serviceAccountAPIKeyController.Get)
+		serviceAccounts.PATCH("/:id/api-keys/:keyID", serviceAccountAPIKeyController.Patch)
+		serviceAccounts.DELETE("/:id/api-keys/:keyID", serviceAccountAPIKeyController.Delete)
 	}
 
 	// Add other v1 route groups here
+	return nil
 }
 
 // Prepare for future versions
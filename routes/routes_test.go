@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/internal/testutil"
+	"github.com/canhbk/golang-gin-starter-kit/mail"
+	"github.com/canhbk/golang-gin-starter-kit/middleware"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/services"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestVerifiedEmailRoutes(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	cfg := config.Default()
+	cfg.Security.MFA.EncryptionKey = "Y2hhbmdlX21lX2NoYW5nZV9tZV9jaGFuZ2VfbWVfISE="
+	cfg.Security.MFA.RequiredRoles = ""
+
+	previousJWT := config.JWT
+	config.InitializeJWT(config.JWTConfig{Secret: "test-secret", Issuer: "test", AccessTokenTTL: time.Minute})
+	t.Cleanup(func() { config.InitializeJWT(previousJWT) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	if err := InitializeRoutes(router, db, cfg, mail.NewLogSender("test@example.com"), services.NewBackground()); err != nil {
+		t.Fatalf("InitializeRoutes: %v", err)
+	}
+
+	role := models.Role{Name: "exporter", Permissions: []models.Permission{{Name: models.PermissionUsersList}}}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	verifiedAt := time.Now()
+	verified := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash",
+		EmailVerifiedAt: &verifiedAt, Roles: []models.Role{role}}
+	unverified := &models.User{Username: "bob", Email: "bob@example.com", Password: "hash", Roles: []models.Role{role}}
+	for _, user := range []*models.User{verified, unverified} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	users := repositories.NewUserRepository(db)
+	accounts := repositories.NewServiceAccountRepository(db)
+	keys := services.NewAPIKeyService(repositories.NewAPIKeyRepository(db), accounts, users,
+		services.NewPermissionService(repositories.NewRoleRepository(db), users, nil), cfg.Security.APIKeys)
+	account, err := services.NewServiceAccountService(accounts).Create(ctx, verified.ID, "nightly", "")
+	if err != nil {
+		t.Fatalf("create service account: %v", err)
+	}
+	_, accountKey, err := keys.Create(ctx, repositories.APIKeyOwner{ServiceAccountID: account.ID}, verified.ID,
+		services.CreateAPIKeyInput{Name: "export", Scopes: []string{models.PermissionUsersList}})
+	if err != nil {
+		t.Fatalf("create service account key: %v", err)
+	}
+
+	bearer := func(user *models.User) string {
+		token, err := utils.GenerateAccessToken(user.ID)
+		if err != nil {
+			t.Fatalf("generate token: %v", err)
+		}
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantCode      string
+	}{
+		{"verified user", "/api/v1/users", bearer(verified), http.StatusOK, ""},
+		{"unverified user", "/api/v1/users", bearer(unverified), http.StatusForbidden, "email_not_verified"},
+		{"unverified user on own account", "/api/v1/users/" + strconv.Itoa(int(unverified.ID)), bearer(unverified), http.StatusOK, ""},
+		{"unverified user's API keys", "/api/v1/users/" + strconv.Itoa(int(unverified.ID)) + "/api-keys", bearer(unverified), http.StatusForbidden, "email_not_verified"},
+		{"service account key", "/api/v1/users", "ApiKey " + accountKey, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var problem struct {
+				Code string `json:"code"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Code != tt.wantCode {
+				t.Errorf("body = %s, want code %s", rec.Body, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+)
+
+const (
+	// apiKeyTag starts every key so that leaked keys are easy to recognise
+	// and scan for
+	apiKeyTag = "sk_"
+	// apiKeyPrefixBytes is the size of the random lookup part of a key's
+	// prefix, 48 bits
+	apiKeyPrefixBytes = 6
+)
+
+var (
+	ErrInvalidAPIKey  = apperror.Unauthorized("invalid_api_key", "The API key is invalid or expired")
+	ErrAPIKeyNotFound = apperror.NotFound("api_key_not_found", "No API key exists with the provided ID")
+	ErrAPIKeyNotOwner = apperror.Forbidden(apperror.CodeForbidden, "Only the owner of an account can issue or change its API keys")
+)
+
+// CreateAPIKeyInput describes a new API key
+type CreateAPIKeyInput struct {
+	Name   string
+	Scopes []string
+	// ExpiresAt defaults to the configured lifetime when nil
+	ExpiresAt *time.Time
+}
+
+// UpdateAPIKeyInput lists the changes to an API key; nil fields are left as
+// they are
+type UpdateAPIKeyInput struct {
+	Name      *string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// APIKeyService issues API keys to users and service accounts and resolves
+// the keys presented with requests
+type APIKeyService interface {
+	// Create issues a key to owner on behalf of actorID and returns it with
+	// its plaintext value, which is only stored hashed and cannot be shown
+	// again. Users may only issue keys to themselves, and the scopes must be
+	// permissions the actor holds.
+	Create(ctx context.Context, owner repositories.APIKeyOwner, actorID uint, input CreateAPIKeyInput) (*models.APIKey, string, error)
+	List(ctx context.Context, owner repositories.APIKeyOwner) ([]models.APIKey, error)
+	Get(ctx context.Context, owner repositories.APIKeyOwner, id uint) (*models.APIKey, error)
+	// Update changes a key under the same rules as Create
+	Update(ctx context.Context, owner repositories.APIKeyOwner, actorID, id uint, input UpdateAPIKeyInput) (*models.APIKey, error)
+	Delete(ctx context.Context, owner repositories.APIKeyOwner, id uint) error
+	// AuthenticateAPIKey returns the key and the permissions it grants. A
+	// user's key never grants more than the user currently holds, so taking
+	// away a role also narrows their keys, and a user whose role requires
+	// MFA cannot use their keys until they enable it.
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, []string, error)
+}
+
+type apiKeyService struct {
+	keys        repositories.APIKeyRepository
+	accounts    repositories.ServiceAccountRepository
+	users       repositories.UserRepository
+	permissions PermissionService
+	cfg         config.APIKeyConfig
+}
+
+func NewAPIKeyService(keys repositories.APIKeyRepository, accounts repositories.ServiceAccountRepository,
+	users repositories.UserRepository, permissions PermissionService, cfg config.APIKeyConfig) APIKeyService {
+	return &apiKeyService{keys: keys, accounts: accounts, users: users, permissions: permissions, cfg: cfg}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, owner repositories.APIKeyOwner, actorID uint,
+	input CreateAPIKeyInput) (*models.APIKey, string, error) {
+	if err := s.checkOwner(ctx, owner); err != nil {
+		return nil, "", err
+	}
+	if owner.UserID != 0 && owner.UserID != actorID {
+		return nil, "", ErrAPIKeyNotOwner
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.DefaultTTL)
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+	scopes, err := s.validate(ctx, actorID, input.Scopes, &expiresAt, now)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefix, err := newAPIKeyPrefix()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		Name:       input.Name,
+		Prefix:     prefix,
+		SecretHash: hash,
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+	}
+	if owner.ServiceAccountID != 0 {
+		key.ServiceAccountID = &owner.ServiceAccountID
+	} else {
+		key.UserID = &owner.UserID
+	}
+	if err := s.keys.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key, prefix + "_" + secret, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, owner repositories.APIKeyOwner) ([]models.APIKey, error) {
+	if err := s.checkOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	return s.keys.List(ctx, owner)
+}
+
+func (s *apiKeyService) Get(ctx context.Context, owner repositories.APIKeyOwner, id uint) (*models.APIKey, error) {
+	if err := s.checkOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	key, err := s.keys.Find(ctx, owner, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (s *apiKeyService) Update(ctx context.Context, owner repositories.APIKeyOwner, actorID, id uint,
+	input UpdateAPIKeyInput) (*models.APIKey, error) {
+	key, err := s.Get(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if owner.UserID != 0 && owner.UserID != actorID {
+		return nil, ErrAPIKeyNotOwner
+	}
+
+	var columns []string
+	if input.Name != nil {
+		key.Name = *input.Name
+		columns = append(columns, "name")
+	}
+	if input.Scopes != nil || input.ExpiresAt != nil {
+		scopes, err := s.validate(ctx, actorID, input.Scopes, input.ExpiresAt, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if input.Scopes != nil {
+			key.Scopes = scopes
+			columns = append(columns, "scopes")
+		}
+		if input.ExpiresAt != nil {
+			key.ExpiresAt = *input.ExpiresAt
+			columns = append(columns, "expires_at")
+		}
+	}
+	if len(columns) == 0 {
+		return key, nil
+	}
+
+	if err := s.keys.Update(ctx, key, columns...); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *apiKeyService) Delete(ctx context.Context, owner repositories.APIKeyOwner, id uint) error {
+	if err := s.checkOwner(ctx, owner); err != nil {
+		return err
+	}
+	err := s.keys.Delete(ctx, owner, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, value string) (*models.APIKey, []string, error) {
+	prefix, secret, ok := splitAPIKey(value)
+	if !ok {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	key, err := s.keys.FindByPrefix(ctx, prefix)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(key.SecretHash)) != 1 || key.IsExpired(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	scopes := key.ScopeList()
+	if key.UserID != nil {
+		// Deleted users keep their rows until purged, but not their keys
+		if _, err := s.users.FindByID(ctx, *key.UserID); err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return nil, nil, ErrInvalidAPIKey
+			}
+			return nil, nil, err
+		}
+		held, err := s.permissions.PermissionsForUser(ctx, *key.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		scopes = slices.DeleteFunc(scopes, func(scope string) bool {
+			return !slices.Contains(held, scope)
+		})
+	}
+
+	if err := s.keys.Touch(ctx, key.ID, now, now.Add(-s.cfg.LastUsedInterval)); err != nil {
+		return nil, nil, err
+	}
+	return key, scopes, nil
+}
+
+// checkOwner reports a missing user or service account as not found
+func (s *apiKeyService) checkOwner(ctx context.Context, owner repositories.APIKeyOwner) error {
+	if owner.ServiceAccountID != 0 {
+		_, err := s.accounts.FindByID(ctx, owner.ServiceAccountID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			return ErrServiceAccountNotFound
+		}
+		return err
+	}
+	_, err := s.users.FindByID(ctx, owner.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// validate checks the scopes and expiry that are set and returns the scopes
+// deduplicated, sorted and joined for storage. Scopes are limited to the
+// actor's own permissions, so that nobody can issue a key more powerful
+// than themselves.
+func (s *apiKeyService) validate(ctx context.Context, actorID uint, scopes []string, expiresAt *time.Time,
+	now time.Time) (string, error) {
+	var fields []apperror.FieldError
+
+	if scopes != nil {
+		held, err := s.permissions.PermissionsForUser(ctx, actorID)
+		if err != nil {
+			return "", err
+		}
+		scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+
+		var missing []string
+		for _, scope := range scopes {
+			if !slices.Contains(held, scope) {
+				missing = append(missing, scope)
+			}
+		}
+		switch {
+		case len(scopes) == 0:
+			fields = append(fields, apperror.FieldError{Field: "scopes", Code: "required", Message: "must list at least one permission"})
+		case len(missing) > 0:
+			fields = append(fields, apperror.FieldError{Field: "scopes", Code: "not_granted",
+				Message: "includes permissions you do not hold: " + strings.Join(missing, ", ")})
+		}
+	}
+
+	if expiresAt != nil {
+		if latest := now.Add(s.cfg.MaxTTL); !expiresAt.After(now) {
+			fields = append(fields, apperror.FieldError{Field: "expires_at", Code: "past", Message: "must be in the future"})
+		} else if expiresAt.After(latest) {
+			fields = append(fields, apperror.FieldError{Field: "expires_at", Code: "too_late",
+				Message: "must not be later than " + latest.UTC().Format(time.RFC3339)})
+		}
+	}
+
+	if len(fields) > 0 {
+		return "", apperror.Validation("The request contains invalid fields", fields...)
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+// newAPIKeyPrefix returns the public part of a new key, which identifies it
+// in listings and logs
+func newAPIKeyPrefix() (string, error) {
+	buf := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyTag + hex.EncodeToString(buf), nil
+}
+
+// splitAPIKey separates a presented key into its prefix and secret. The
+// secret may itself contain underscores.
+func splitAPIKey(value string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(value, apiKeyTag)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != 2*apiKeyPrefixBytes || secret == "" {
+		return "", "", false
+	}
+	return apiKeyTag + id, secret, true
+}
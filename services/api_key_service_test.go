@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/internal/testutil"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+func TestAPIKeyScopesFollowTheOwnersPermissions(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	users := repositories.NewUserRepository(db)
+	svc := NewAPIKeyService(repositories.NewAPIKeyRepository(db), repositories.NewServiceAccountRepository(db), users,
+		NewPermissionService(repositories.NewRoleRepository(db), users, nil), config.APIKeyConfig{
+			DefaultTTL:       time.Hour,
+			MaxTTL:           24 * time.Hour,
+			LastUsedInterval: time.Minute,
+		})
+
+	read := models.Permission{Name: models.PermissionUsersRead}
+	list := models.Permission{Name: models.PermissionUsersList}
+	role := models.Role{Name: "support", Permissions: []models.Permission{read, list}}
+	if err := db.Create(&role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash", Roles: []models.Role{role}}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	owner := repositories.APIKeyOwner{UserID: user.ID}
+
+	// Scopes are limited to what the owner holds when the key is issued
+	_, _, err := svc.Create(ctx, owner, user.ID, CreateAPIKeyInput{
+		Name:   "too much",
+		Scopes: []string{models.PermissionUsersRead, models.PermissionUsersDelete},
+	})
+	if appErr := apperror.As(err); appErr.Kind != apperror.KindValidation || len(appErr.Fields) != 1 ||
+		appErr.Fields[0].Code != "not_granted" {
+		t.Fatalf("create with a permission the user lacks: error = %v, want a not_granted validation error", err)
+	}
+
+	_, value, err := svc.Create(ctx, owner, user.ID, CreateAPIKeyInput{
+		Name:   "support tool",
+		Scopes: []string{models.PermissionUsersList, models.PermissionUsersRead, models.PermissionUsersRead},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_, scopes, err := svc.AuthenticateAPIKey(ctx, value)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if want := []string{models.PermissionUsersList, models.PermissionUsersRead}; !slices.Equal(scopes, want) {
+		t.Errorf("scopes = %v, want %v", scopes, want)
+	}
+
+	// Taking a permission away from the role narrows the key at once
+	var revoked models.Permission
+	if err := db.Where("name = ?", models.PermissionUsersList).First(&revoked).Error; err != nil {
+		t.Fatalf("find permission: %v", err)
+	}
+	if err := db.Model(&role).Association("Permissions").Delete(&revoked); err != nil {
+		t.Fatalf("revoke permission: %v", err)
+	}
+	_, scopes, err = svc.AuthenticateAPIKey(ctx, value)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if want := []string{models.PermissionUsersRead}; !slices.Equal(scopes, want) {
+		t.Errorf("scopes after revoking %s = %v, want %v", models.PermissionUsersList, scopes, want)
+	}
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+)
+
+var (
+	ErrInvalidCredentials  = apperror.Unauthorized("invalid_credentials", "Username or password is incorrect")
+	ErrInvalidRefreshToken = apperror.Unauthorized("invalid_refresh_token", "Refresh token is invalid or expired")
+)
+
+// TokenPair is an access token together with the refresh token that renews it
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// LoginResult is the outcome of a correct password. Users with MFA get a
+// challenge token to present with their code instead of a token pair.
+type LoginResult struct {
+	Tokens            *TokenPair
+	MFAToken          string
+	MFATokenExpiresIn time.Duration
+}
+
+// AuthService issues, rotates and revokes user tokens
+type AuthService interface {
+	// Login checks a password from the client at ip. Failures count towards
+	// the lockout of both the account and the IP.
+	Login(ctx context.Context, login, password, ip string) (*LoginResult, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+type authService struct {
+	users     repositories.UserRepository
+	tokens    repositories.RefreshTokenRepository
+	mfa       repositories.MFARepository
+	passwords PasswordService
+	lockout   LockoutService
+	// mfaChallengeTTL bounds the time between the password and code steps
+	mfaChallengeTTL time.Duration
+}
+
+func NewAuthService(users repositories.UserRepository, tokens repositories.RefreshTokenRepository,
+	mfa repositories.MFARepository, passwords PasswordService, lockout LockoutService,
+	mfaChallengeTTL time.Duration) AuthService {
+	return &authService{
+		users:           users,
+		tokens:          tokens,
+		mfa:             mfa,
+		passwords:       passwords,
+		lockout:         lockout,
+		mfaChallengeTTL: mfaChallengeTTL,
+	}
+}
+
+func (s *authService) Login(ctx context.Context, login, password, ip string) (*LoginResult, error) {
+	user, err := s.users.FindByUsernameOrEmail(ctx, login)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return nil, err
+	}
+
+	var userID uint
+	if user != nil {
+		userID = user.ID
+	}
+	// Throttled attempts are refused before the password is looked at, so
+	// they cannot be used to keep guessing
+	if err := s.lockout.Check(ctx, userID, login, ip); err != nil {
+		return nil, err
+	}
+
+	// Unknown users are verified against a dummy hash, so that they take
+	// as long to reject as wrong passwords
+	match, err := s.passwords.Verify(ctx, user, password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, s.loginFailed(ctx, userID, login, ip)
+	}
+
+	if user.MFAEnabledAt != nil {
+		// Only the hash of the challenge ID is stored, and a new login
+		// replaces an earlier challenge that was not used
+		id, hash, err := utils.GenerateOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.mfa.SetChallenge(ctx, user.ID, hash); err != nil {
+			return nil, err
+		}
+		challenge, err := utils.GenerateMFAChallengeToken(user.ID, id, s.mfaChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		// The account's failures are kept until the code step succeeds
+		return &LoginResult{MFAToken: challenge, MFATokenExpiresIn: s.mfaChallengeTTL}, nil
+	}
+
+	if err := s.lockout.Succeed(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	pair, record, err := newTokenPair(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokens.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair}, nil
+}
+
+// loginFailed counts a wrong password and returns the error to report
+func (s *authService) loginFailed(ctx context.Context, userID uint, login, ip string) error {
+	if err := s.lockout.Fail(ctx, userID, login, ip); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	current, err := s.tokens.FindByHash(ctx, utils.HashToken(refreshToken))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RevokedAt != nil {
+		// A revoked token being replayed means it has leaked, so every
+		// session of the user is terminated
+		if err := s.tokens.RevokeAllForUser(ctx, current.UserID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !current.IsActive(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if _, err := s.users.FindByID(ctx, current.UserID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	pair, replacement, err := newTokenPair(current.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokens.Rotate(ctx, current, replacement); err != nil {
+		if errors.Is(err, repositories.ErrTokenRevoked) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return pair, nil
+}
+
+func (s *authService) Logout(ctx context.Context, refreshToken string) error {
+	return s.tokens.RevokeByHash(ctx, utils.HashToken(refreshToken))
+}
+
+// newTokenPair signs an access token and prepares the refresh token record to persist
+func newTokenPair(userID uint) (*TokenPair, *models.RefreshToken, error) {
+	accessToken, err := utils.GenerateAccessToken(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshHash, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: refreshHash,
+		ExpiresAt: time.Now().Add(config.JWT.RefreshTokenTTL),
+	}
+	pair := &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    config.JWT.AccessTokenTTL,
+	}
+	return pair, record, nil
+}
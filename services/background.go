@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// Background runs work that outlives the request that started it, such as
+// sending mail, and lets shutdown wait for it to finish
+type Background struct {
+	wg sync.WaitGroup
+}
+
+func NewBackground() *Background {
+	return &Background{}
+}
+
+// Go runs fn in a new goroutine
+func (b *Background) Go(fn func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until all work started with Go has finished, or returns the
+// error of ctx once it is done first
+func (b *Background) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+var errMalformedCursor = errors.New("malformed cursor")
+
+// userCursor is the decoded form of the opaque cursor handed to clients. It
+// records the last row of a page and the direction the listing runs in.
+type userCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+	Desc      bool      `json:"d,omitempty"`
+}
+
+func (c userCursor) key() *repositories.UserKey {
+	return &repositories.UserKey{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+func encodeUserCursor(c userCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeUserCursor(s string) (userCursor, error) {
+	var c userCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errMalformedCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 || c.CreatedAt.IsZero() {
+		return c, errMalformedCursor
+	}
+	return c, nil
+}
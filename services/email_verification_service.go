@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/mail"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+)
+
+var (
+	ErrInvalidVerificationToken = apperror.BadRequest("invalid_verification_token", "Email verification link is invalid or expired")
+	ErrEmailAlreadyVerified     = apperror.Conflict("email_already_verified", "Email address is already verified")
+	ErrVerificationThrottled    = apperror.TooManyRequests("verification_throttled", "A verification email was sent recently")
+)
+
+// EmailVerificationService confirms that users own their email address by
+// sending them a signed link
+type EmailVerificationService interface {
+	// SendVerification emails a verification link to the user's current
+	// address. It is not throttled and is meant to follow signups and email
+	// changes.
+	SendVerification(ctx context.Context, user *models.User) error
+	Verify(ctx context.Context, token string) (*models.User, error)
+	// Resend emails a new link unless the address is verified or a link was
+	// sent less than the resend interval ago
+	Resend(ctx context.Context, userID uint) error
+	// NotifyEmailChanged tells the previous address that the account now uses
+	// the user's current one, so that an owner who did not make the change
+	// notices it
+	NotifyEmailChanged(ctx context.Context, user *models.User, previous string)
+	// IsEmailVerified reports whether the user has confirmed their current
+	// address, and backs middleware.RequireVerifiedEmail
+	IsEmailVerified(ctx context.Context, userID uint) (bool, error)
+}
+
+type emailVerificationService struct {
+	users      repositories.UserRepository
+	mailer     mail.Sender
+	background *Background
+	cfg        config.EmailVerificationConfig
+}
+
+func NewEmailVerificationService(users repositories.UserRepository, mailer mail.Sender, background *Background,
+	cfg config.EmailVerificationConfig) EmailVerificationService {
+	return &emailVerificationService{users: users, mailer: mailer, background: background, cfg: cfg}
+}
+
+func (s *emailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	now := time.Now()
+	if _, err := s.users.MarkVerificationSent(ctx, user.ID, now, now); err != nil {
+		return err
+	}
+	return s.send(ctx, user)
+}
+
+func (s *emailVerificationService) Verify(ctx context.Context, token string) (*models.User, error) {
+	claims, err := utils.ParseEmailVerificationToken(token)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.users.FindByID(ctx, claims.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != claims.Email {
+		// The address changed after the link was sent
+		return nil, ErrInvalidVerificationToken
+	}
+	if user.EmailVerifiedAt != nil {
+		// Following the link twice is harmless
+		return user, nil
+	}
+
+	if _, err := s.users.MarkEmailVerified(ctx, user.ID, claims.Email, time.Now()); err != nil {
+		return nil, err
+	}
+	// Reload to pick up the new version, and to see the outcome of a
+	// concurrent email change or verification
+	user, err = s.users.FindByID(ctx, claims.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Email != claims.Email || user.EmailVerifiedAt == nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	return user, nil
+}
+
+func (s *emailVerificationService) Resend(ctx context.Context, userID uint) error {
+	user, err := s.users.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	now := time.Now()
+	sent, err := s.users.MarkVerificationSent(ctx, user.ID, now, now.Add(-s.cfg.ResendInterval))
+	if err != nil {
+		return err
+	}
+	if !sent {
+		if user.VerificationSentAt == nil {
+			// Verified between the read and the write
+			return ErrEmailAlreadyVerified
+		}
+		return ErrVerificationThrottled.WithRetryAfter(user.VerificationSentAt.Add(s.cfg.ResendInterval).Sub(now))
+	}
+	return s.send(ctx, user)
+}
+
+func (s *emailVerificationService) NotifyEmailChanged(ctx context.Context, user *models.User, previous string) {
+	msg := mail.Message{
+		To:      previous,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The email address of your account was changed from %s to %s.\n\n"+
+			"If you did not make this change, reset your password and contact support straight away.\n",
+			user.Username, previous, user.Email),
+	}
+	s.deliver(ctx, msg, user.ID, "failed to send email change notice")
+}
+
+// send signs a link for the user's current address and mails it in the
+// background; delivery failures are logged
+func (s *emailVerificationService) send(ctx context.Context, user *models.User) error {
+	token, err := utils.GenerateEmailVerificationToken(user.ID, user.Email, s.cfg.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link, _ := url.Parse(s.cfg.URL)
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm that this is your email address by opening the link below:\n\n"+
+			"%s\n\n"+
+			"The link expires in %s. If you did not create an account or change your email, ignore this email.\n",
+			user.Username, link, humanDuration(s.cfg.TokenTTL)),
+	}
+	s.deliver(ctx, msg, user.ID, "failed to send verification email")
+	return nil
+}
+
+// deliver sends msg in the background and logs a failure with failMsg
+func (s *emailVerificationService) deliver(ctx context.Context, msg mail.Message, userID uint, failMsg string) {
+	mailCtx := context.WithoutCancel(ctx)
+	s.background.Go(func() {
+		if err := s.mailer.Send(mailCtx, msg); err != nil {
+			logging.FromContext(mailCtx).Error(failMsg, "user_id", userID, "error", err)
+		}
+	})
+}
+
+// IsEmailVerified reports false for users that do not exist, so that a
+// token outliving its user is refused
+func (s *emailVerificationService) IsEmailVerified(ctx context.Context, userID uint) (bool, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HealthChecker probes a single dependency
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to the HealthChecker interface
+type HealthCheckerFunc func(ctx context.Context) error
+
+func (f HealthCheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// HealthCheckResult is the outcome of one registered check
+type HealthCheckResult struct {
+	Name     string
+	Critical bool
+	Healthy  bool
+	Latency  time.Duration
+	Err      error
+}
+
+// HealthReport aggregates the results of every registered check. It is
+// unhealthy when at least one critical check failed.
+type HealthReport struct {
+	Healthy bool
+	Checks  []HealthCheckResult
+}
+
+type registeredCheck struct {
+	name     string
+	critical bool
+	checker  HealthChecker
+}
+
+// HealthRegistry holds the checks probed by the readiness endpoint
+type HealthRegistry struct {
+	mu      sync.RWMutex
+	timeout time.Duration
+	checks  []registeredCheck
+}
+
+// NewHealthRegistry creates a registry whose checks each run with the given timeout
+func NewHealthRegistry(timeout time.Duration) *HealthRegistry {
+	return &HealthRegistry{timeout: timeout}
+}
+
+// Register adds a check. A failing critical check marks the service as not ready;
+// non-critical checks are reported without affecting readiness.
+func (r *HealthRegistry) Register(name string, critical bool, checker HealthChecker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, registeredCheck{name: name, critical: critical, checker: checker})
+}
+
+// Run executes all checks concurrently
+func (r *HealthRegistry) Run(ctx context.Context) HealthReport {
+	r.mu.RLock()
+	checks := make([]registeredCheck, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check registeredCheck) {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Healthy: true, Checks: results}
+	for _, result := range results {
+		if result.Critical && !result.Healthy {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func (r *HealthRegistry) runCheck(ctx context.Context, check registeredCheck) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.checker.Check(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	return HealthCheckResult{
+		Name:     check.name,
+		Critical: check.critical,
+		Healthy:  err == nil,
+		Latency:  time.Since(start),
+		Err:      err,
+	}
+}
+
+// NewDatabaseChecker pings the connection pool behind db
+func NewDatabaseChecker(db *gorm.DB) HealthChecker {
+	return HealthCheckerFunc(func(ctx context.Context) error {
+		if db == nil {
+			return errors.New("database is not initialized")
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
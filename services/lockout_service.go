@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+var (
+	ErrLoginThrottled = apperror.TooManyRequests("login_throttled", "Too many failed attempts, wait before trying again")
+	ErrAccountLocked  = apperror.TooManyRequests("account_locked", "Too many failed attempts, the account is temporarily locked")
+	ErrClientLocked   = apperror.TooManyRequests("client_locked", "Too many failed attempts from this address, try again later")
+	ErrRateLimited    = apperror.TooManyRequests(apperror.CodeTooManyRequests, "Too many requests, wait before trying again")
+)
+
+// LockoutService slows down and then stops password and code guessing.
+// Accounts are identified by user ID, or by the login name when it matches
+// no user, so that unknown names are throttled exactly like real ones.
+type LockoutService interface {
+	// Check fails when the account or the client IP must wait before the
+	// next attempt. userID is 0 when login matches no user.
+	Check(ctx context.Context, userID uint, login, ip string) error
+	// Fail counts a failed attempt and locks out the account or IP once it
+	// reaches its limit
+	Fail(ctx context.Context, userID uint, login, ip string) error
+	// Succeed clears the account's failures. The IP's are kept, so that a
+	// working login cannot be used to reset them.
+	Succeed(ctx context.Context, userID uint) error
+	// Unlock clears a user's failures and lockout on behalf of actorID, which
+	// is zero when a service account's API key made the request
+	Unlock(ctx context.Context, userID, actorID uint) error
+	// Limit counts a request for action by subject and by the client IP in
+	// the same store, and fails with ErrRateLimited once either has made
+	// more than its limit within the window
+	Limit(ctx context.Context, action, subject, ip string, subjectLimit, ipLimit int) error
+}
+
+type lockoutService struct {
+	attempts repositories.LoginAttemptRepository
+	audit    repositories.AuditRepository
+	users    repositories.UserRepository
+	cfg      config.LockoutConfig
+}
+
+func NewLockoutService(attempts repositories.LoginAttemptRepository, audit repositories.AuditRepository,
+	users repositories.UserRepository, cfg config.LockoutConfig) LockoutService {
+	return &lockoutService{attempts: attempts, audit: audit, users: users, cfg: cfg}
+}
+
+func (s *lockoutService) Check(ctx context.Context, userID uint, login, ip string) error {
+	now := time.Now()
+	if err := s.check(ctx, accountIdentifier(userID, login), s.cfg.FreeAttempts, ErrAccountLocked, now); err != nil {
+		return err
+	}
+	return s.check(ctx, ipIdentifier(ip), s.cfg.IPFreeAttempts, ErrClientLocked, now)
+}
+
+func (s *lockoutService) Fail(ctx context.Context, userID uint, login, ip string) error {
+	now := time.Now()
+	account := accountIdentifier(userID, login)
+	locked, err := s.fail(ctx, account, s.cfg.MaxAttempts, now)
+	if err != nil {
+		return err
+	}
+	if locked {
+		event := &models.AuditEvent{Action: models.AuditAccountLocked, Subject: account, IP: ip}
+		if userID != 0 {
+			event.UserID = &userID
+		}
+		s.record(ctx, event, s.cfg.MaxAttempts, now)
+	}
+
+	client := ipIdentifier(ip)
+	locked, err = s.fail(ctx, client, s.cfg.IPMaxAttempts, now)
+	if err != nil {
+		return err
+	}
+	if locked {
+		s.record(ctx, &models.AuditEvent{Action: models.AuditIPLocked, Subject: client, IP: ip}, s.cfg.IPMaxAttempts, now)
+	}
+	return nil
+}
+
+func (s *lockoutService) Succeed(ctx context.Context, userID uint) error {
+	return s.attempts.Delete(ctx, accountIdentifier(userID, ""))
+}
+
+func (s *lockoutService) Unlock(ctx context.Context, userID, actorID uint) error {
+	if _, err := s.users.FindByID(ctx, userID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	account := accountIdentifier(userID, "")
+	if err := s.attempts.Delete(ctx, account); err != nil {
+		return err
+	}
+	event := &models.AuditEvent{
+		Action:  models.AuditAccountUnlocked,
+		Subject: account,
+		UserID:  &userID,
+	}
+	if actorID != 0 {
+		event.ActorID = &actorID
+	}
+	return s.audit.Create(ctx, event)
+}
+
+func (s *lockoutService) Limit(ctx context.Context, action, subject, ip string, subjectLimit, ipLimit int) error {
+	now := time.Now()
+	counters := []struct {
+		identifier string
+		limit      int
+	}{
+		{action + ":" + hashIdentifier(subject), subjectLimit},
+		{action + ":" + ipIdentifier(ip), ipLimit},
+	}
+	for _, counter := range counters {
+		attempt, err := s.attempts.RecordFailure(ctx, counter.identifier, now, s.cfg.Window)
+		if err != nil {
+			return err
+		}
+		if attempt.Failures > counter.limit {
+			return ErrRateLimited.WithRetryAfter(s.cfg.Window)
+		}
+	}
+	return nil
+}
+
+// check returns locked while identifier is locked out, and ErrLoginThrottled
+// while the backoff after its last failure has not elapsed
+func (s *lockoutService) check(ctx context.Context, identifier string, free int, locked *apperror.Error, now time.Time) error {
+	attempt, err := s.attempts.Find(ctx, identifier)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if attempt.IsLocked(now) {
+		return locked.WithRetryAfter(attempt.LockedUntil.Sub(now))
+	}
+	if attempt.LockedUntil != nil || attempt.Failures <= free || now.Sub(attempt.LastFailedAt) > s.cfg.Window {
+		return nil
+	}
+	if next := attempt.LastFailedAt.Add(s.backoff(attempt.Failures - free)); now.Before(next) {
+		return ErrLoginThrottled.WithRetryAfter(next.Sub(now))
+	}
+	return nil
+}
+
+// fail records a failure and reports whether it started a lockout
+func (s *lockoutService) fail(ctx context.Context, identifier string, limit int, now time.Time) (bool, error) {
+	attempt, err := s.attempts.RecordFailure(ctx, identifier, now, s.cfg.Window)
+	if err != nil {
+		return false, err
+	}
+	if attempt.Failures < limit || attempt.IsLocked(now) {
+		return false, nil
+	}
+	return s.attempts.Lock(ctx, identifier, now, now.Add(s.cfg.Duration))
+}
+
+// record audits a lockout. The lockout itself has already happened, so a
+// failure to write the event is logged rather than failing the login.
+func (s *lockoutService) record(ctx context.Context, event *models.AuditEvent, failures int, now time.Time) {
+	event.Detail = fmt.Sprintf("%d failed attempts, locked until %s", failures,
+		now.Add(s.cfg.Duration).UTC().Format(time.RFC3339))
+
+	logger := logging.FromContext(ctx)
+	logger.Warn("login lockout", "action", event.Action, "subject", event.Subject, "ip", event.IP)
+	if err := s.audit.Create(ctx, event); err != nil {
+		logger.Error("failed to record audit event", "action", event.Action, "error", err)
+	}
+}
+
+// backoff is the wait after the nth failure past the free attempts: the base
+// delay doubled n-1 times, capped at the maximum
+func (s *lockoutService) backoff(n int) time.Duration {
+	delay := s.cfg.BaseDelay
+	for i := 1; i < n && delay < s.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxDelay)
+}
+
+// accountIdentifier names the counter of a user, or of a login that matches
+// no user. Unknown logins are hashed, since users sometimes type their
+// password into the username field.
+func accountIdentifier(userID uint, login string) string {
+	if userID != 0 {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "login:" + hashIdentifier(login)
+}
+
+// hashIdentifier keeps user input such as logins and email addresses out of
+// the counters' identifiers
+func hashIdentifier(value string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(value)))
+	return hex.EncodeToString(sum[:])
+}
+
+func ipIdentifier(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/internal/testutil"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+var testLockoutConfig = config.LockoutConfig{
+	Window:         time.Hour,
+	Duration:       15 * time.Minute,
+	BaseDelay:      time.Second,
+	MaxDelay:       4 * time.Second,
+	FreeAttempts:   2,
+	MaxAttempts:    7,
+	IPFreeAttempts: 100,
+	IPMaxAttempts:  1000,
+}
+
+// lockoutStores runs a test against both login attempt stores
+func lockoutStores(t *testing.T, test func(t *testing.T, s *lockoutService)) {
+	t.Run("database", func(t *testing.T) {
+		db := testutil.NewDB(t)
+		test(t, NewLockoutService(repositories.NewLoginAttemptRepository(db), repositories.NewAuditRepository(db),
+			repositories.NewUserRepository(db), testLockoutConfig).(*lockoutService))
+	})
+	t.Run("memory", func(t *testing.T) {
+		db := testutil.NewDB(t)
+		test(t, NewLockoutService(repositories.NewMemoryLoginAttemptRepository(), repositories.NewAuditRepository(db),
+			repositories.NewUserRepository(db), testLockoutConfig).(*lockoutService))
+	})
+}
+
+// retryAfter returns the wait requested by a refusal matching want
+func retryAfter(t *testing.T, err error, want *apperror.Error) time.Duration {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("error = %v, want %s", err, want.Code)
+	}
+	return apperror.As(err).RetryAfter
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	lockoutStores(t, func(t *testing.T, s *lockoutService) {
+		ctx := context.Background()
+		const account = "user:1"
+		now := time.Now().Truncate(time.Second)
+		check := func(at time.Time) error {
+			return s.check(ctx, account, testLockoutConfig.FreeAttempts, ErrAccountLocked, at)
+		}
+		fail := func(at time.Time) {
+			t.Helper()
+			if _, err := s.fail(ctx, account, testLockoutConfig.MaxAttempts, at); err != nil {
+				t.Fatalf("fail: %v", err)
+			}
+		}
+
+		// The free attempts are not slowed down
+		for range testLockoutConfig.FreeAttempts {
+			fail(now)
+			if err := check(now); err != nil {
+				t.Fatalf("check within the free attempts: %v", err)
+			}
+		}
+
+		// Each further failure doubles the wait, up to the maximum
+		for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+			fail(now)
+			if wait := retryAfter(t, check(now), ErrLoginThrottled); wait != delay {
+				t.Errorf("wait = %s, want %s", wait, delay)
+			}
+			if err := check(now.Add(delay - time.Millisecond)); !errors.Is(err, ErrLoginThrottled) {
+				t.Errorf("check just before the wait is over = %v, want throttled", err)
+			}
+			now = now.Add(delay)
+			if err := check(now); err != nil {
+				t.Errorf("check once the wait is over: %v", err)
+			}
+		}
+	})
+}
+
+func TestLockoutExpiry(t *testing.T) {
+	lockoutStores(t, func(t *testing.T, s *lockoutService) {
+		ctx := context.Background()
+		const account = "user:1"
+		now := time.Now().Truncate(time.Second)
+		check := func(at time.Time) error {
+			return s.check(ctx, account, testLockoutConfig.FreeAttempts, ErrAccountLocked, at)
+		}
+
+		for i := 1; i <= testLockoutConfig.MaxAttempts; i++ {
+			locked, err := s.fail(ctx, account, testLockoutConfig.MaxAttempts, now)
+			if err != nil {
+				t.Fatalf("fail: %v", err)
+			}
+			if want := i == testLockoutConfig.MaxAttempts; locked != want {
+				t.Fatalf("failure %d locked = %t, want %t", i, locked, want)
+			}
+		}
+
+		if wait := retryAfter(t, check(now), ErrAccountLocked); wait != testLockoutConfig.Duration {
+			t.Errorf("wait = %s, want %s", wait, testLockoutConfig.Duration)
+		}
+		later := now.Add(testLockoutConfig.Duration - time.Minute)
+		if wait := retryAfter(t, check(later), ErrAccountLocked); wait != time.Minute {
+			t.Errorf("wait = %s, want %s", wait, time.Minute)
+		}
+
+		// Once the lockout expires the account may try again, and the next
+		// failure starts the count over instead of locking it again
+		now = now.Add(testLockoutConfig.Duration)
+		if err := check(now); err != nil {
+			t.Fatalf("check after the lockout: %v", err)
+		}
+		locked, err := s.fail(ctx, account, testLockoutConfig.MaxAttempts, now)
+		if err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		if locked {
+			t.Error("the first failure after a lockout locked the account again")
+		}
+		if err := check(now); err != nil {
+			t.Errorf("check after one new failure: %v", err)
+		}
+	})
+}
+
+func TestLockoutWindow(t *testing.T) {
+	lockoutStores(t, func(t *testing.T, s *lockoutService) {
+		ctx := context.Background()
+		const account = "user:1"
+		now := time.Now().Truncate(time.Second)
+
+		for range testLockoutConfig.FreeAttempts + 1 {
+			if _, err := s.fail(ctx, account, testLockoutConfig.MaxAttempts, now); err != nil {
+				t.Fatalf("fail: %v", err)
+			}
+		}
+		if err := s.check(ctx, account, testLockoutConfig.FreeAttempts, ErrAccountLocked, now); !errors.Is(err, ErrLoginThrottled) {
+			t.Fatalf("check = %v, want throttled", err)
+		}
+
+		// Failures older than the window are forgotten
+		now = now.Add(testLockoutConfig.Window + time.Second)
+		if _, err := s.fail(ctx, account, testLockoutConfig.MaxAttempts, now); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		if err := s.check(ctx, account, testLockoutConfig.FreeAttempts, ErrAccountLocked, now); err != nil {
+			t.Errorf("check after the window: %v", err)
+		}
+	})
+}
+
+func TestLimitCountsSubjectAndIP(t *testing.T) {
+	lockoutStores(t, func(t *testing.T, s *lockoutService) {
+		ctx := context.Background()
+		limit := func(subject, ip string) error {
+			return s.Limit(ctx, "reset", subject, ip, 2, 3)
+		}
+
+		for range 2 {
+			if err := limit("a@example.com", "203.0.113.1"); err != nil {
+				t.Fatalf("request within the limit: %v", err)
+			}
+		}
+		if wait := retryAfter(t, limit("A@example.com", "203.0.113.2"), ErrRateLimited); wait != testLockoutConfig.Window {
+			t.Errorf("wait = %s, want %s", wait, testLockoutConfig.Window)
+		}
+
+		// The first IP has made two requests, so one more for another
+		// address is allowed and the next is not
+		if err := limit("b@example.com", "203.0.113.1"); err != nil {
+			t.Fatalf("third request from the IP: %v", err)
+		}
+		if err := limit("c@example.com", "203.0.113.1"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("fourth request from the IP = %v, want rate limited", err)
+		}
+	})
+}
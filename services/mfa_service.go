@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpPeriod and totpDigits match what authenticator apps assume when
+	// the otpauth URI does not say otherwise
+	totpPeriod = 30
+	totpDigits = otp.DigitsSix
+	// recoveryCodeCount is how many recovery codes a confirmed enrolment yields
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of base32 characters per code, 50 bits
+	recoveryCodeLength = 10
+)
+
+// recoveryCodeAlphabet is lowercase base32, which avoids 0/O and 1/l mix-ups
+const recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+var (
+	ErrMFAAlreadyEnabled = apperror.Conflict("mfa_already_enabled", "Multi-factor authentication is already enabled")
+	ErrMFANotEnabled     = apperror.Conflict("mfa_not_enabled", "Multi-factor authentication is not enabled")
+	ErrMFANotEnrolled    = apperror.Conflict("mfa_not_enrolled", "Start an enrolment before confirming it")
+	ErrInvalidMFACode    = apperror.BadRequest("invalid_mfa_code", "The authentication code is invalid or was already used")
+	ErrInvalidMFAToken   = apperror.Unauthorized("invalid_mfa_token", "The login challenge is invalid or expired")
+	ErrIncorrectPassword = apperror.Validation("The password is incorrect",
+		apperror.FieldError{Field: "password", Code: "incorrect", Message: "is incorrect"})
+)
+
+// MFAEnrollment is a new, unconfirmed TOTP secret
+type MFAEnrollment struct {
+	Secret string
+	// URI is the otpauth:// URI that authenticator apps import, usually
+	// through a QR code
+	URI string
+}
+
+// MFAStatus describes a user's second factor
+type MFAStatus struct {
+	Enabled                bool
+	RecoveryCodesRemaining int64
+}
+
+// MFAService manages TOTP second factors and completes logins that require one
+type MFAService interface {
+	Status(ctx context.Context, userID uint) (*MFAStatus, error)
+	// Enroll and Disable require the current password, so that a stolen
+	// access token cannot change the second factor. Wrong passwords, and
+	// wrong codes for Disable, count towards the lockout like failed logins
+	// from ip.
+	Enroll(ctx context.Context, userID uint, password, ip string) (*MFAEnrollment, error)
+	// Confirm enables MFA once the user proves their app produces valid
+	// codes, and returns the recovery codes. They are only stored hashed and
+	// cannot be shown again.
+	Confirm(ctx context.Context, userID uint, code string) ([]string, error)
+	Disable(ctx context.Context, userID uint, password, code, ip string) error
+	// CompleteLogin exchanges the challenge token from Login and a TOTP or
+	// recovery code for a token pair. Wrong codes count towards the lockout
+	// like wrong passwords. A challenge is used up by the exchange.
+	CompleteLogin(ctx context.Context, challengeToken, code, ip string) (*TokenPair, error)
+}
+
+type mfaService struct {
+	users     repositories.UserRepository
+	mfa       repositories.MFARepository
+	tokens    repositories.RefreshTokenRepository
+	passwords PasswordService
+	lockout   LockoutService
+	cipher    *utils.Cipher
+	// recoveryKey keys the recovery code hashes, so that a leaked table
+	// cannot be brute forced without the encryption key as well
+	recoveryKey []byte
+	issuer      string
+}
+
+func NewMFAService(users repositories.UserRepository, mfa repositories.MFARepository,
+	tokens repositories.RefreshTokenRepository, passwords PasswordService, lockout LockoutService,
+	cfg config.MFAConfig) (MFAService, error) {
+	key, err := cfg.Key()
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := utils.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("recovery-codes"))
+
+	return &mfaService{
+		users:       users,
+		mfa:         mfa,
+		tokens:      tokens,
+		passwords:   passwords,
+		lockout:     lockout,
+		cipher:      cipher,
+		recoveryKey: mac.Sum(nil),
+		issuer:      cfg.Issuer,
+	}, nil
+}
+
+func (s *mfaService) Status(ctx context.Context, userID uint) (*MFAStatus, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt == nil {
+		return &MFAStatus{}, nil
+	}
+
+	remaining, err := s.mfa.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAStatus{Enabled: true, RecoveryCodesRemaining: remaining}, nil
+}
+
+func (s *mfaService) Enroll(ctx context.Context, userID uint, password, ip string) (*MFAEnrollment, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.verifyPassword(ctx, user, password, ip); err != nil {
+		return nil, err
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.issuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      totpDigits,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.cipher.Encrypt([]byte(key.Secret()), secretBinding(user.ID))
+	if err != nil {
+		return nil, err
+	}
+	err = s.mfa.SavePendingSecret(ctx, user.ID, encrypted)
+	if errors.Is(err, repositories.ErrMFAStateChanged) {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &MFAEnrollment{Secret: key.Secret(), URI: key.URL()}, nil
+}
+
+func (s *mfaService) Confirm(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	step, err := s.matchTOTP(user, normalizeCode(code))
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = s.hashRecoveryCode(codes[i])
+	}
+
+	err = s.mfa.Enable(ctx, user.ID, user.MFASecret, step, hashes)
+	if errors.Is(err, repositories.ErrMFAStateChanged) {
+		// Confirmed or re-enrolled by a concurrent request
+		return nil, ErrEditConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) Disable(ctx context.Context, userID uint, password, code, ip string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.MFAEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+	if err := s.verifyPassword(ctx, user, password, ip); err != nil {
+		return err
+	}
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.lockout.Fail(ctx, user.ID, "", ip); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	err = s.mfa.Disable(ctx, user.ID)
+	if errors.Is(err, repositories.ErrMFAStateChanged) {
+		return ErrMFANotEnabled
+	}
+	return err
+}
+
+func (s *mfaService) CompleteLogin(ctx context.Context, challengeToken, code, ip string) (*TokenPair, error) {
+	claims, err := utils.ParseMFAChallengeToken(challengeToken)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.users.FindByID(ctx, claims.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt == nil {
+		// MFA was disabled since the password step; start over
+		return nil, ErrInvalidMFAToken
+	}
+	challengeHash := utils.HashToken(claims.ID)
+	if subtle.ConstantTimeCompare([]byte(user.MFAChallenge), []byte(challengeHash)) != 1 {
+		// Used already, or replaced by a later login
+		return nil, ErrInvalidMFAToken
+	}
+
+	if err := s.lockout.Check(ctx, user.ID, "", ip); err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.lockout.Fail(ctx, user.ID, "", ip); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	// A concurrent exchange of the same challenge may have won the race
+	used, err := s.mfa.UseChallenge(ctx, user.ID, challengeHash)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidMFAToken
+	}
+	if err := s.lockout.Succeed(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	pair, record, err := newTokenPair(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokens.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// verifyPassword checks the current password before a change to the second
+// factor, under the same lockout as logins
+func (s *mfaService) verifyPassword(ctx context.Context, user *models.User, password, ip string) error {
+	if err := s.lockout.Check(ctx, user.ID, "", ip); err != nil {
+		return err
+	}
+	match, err := s.passwords.Verify(ctx, user, password)
+	if err != nil {
+		return err
+	}
+	if !match {
+		if err := s.lockout.Fail(ctx, user.ID, "", ip); err != nil {
+			return err
+		}
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code, and uses it up
+func (s *mfaService) verifySecondFactor(ctx context.Context, user *models.User, code string) error {
+	code = normalizeCode(code)
+
+	if isTOTPCode(code) {
+		step, err := s.matchTOTP(user, code)
+		if err != nil {
+			return err
+		}
+		used, err := s.mfa.UseStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			// Replay of a code that was already accepted
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.mfa.UseRecoveryCode(ctx, user.ID, s.hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// matchTOTP returns the time step of code, allowing one step of clock drift
+// either way
+func (s *mfaService) matchTOTP(user *models.User, code string) (int64, error) {
+	secret, err := s.cipher.Decrypt(user.MFASecret, secretBinding(user.ID))
+	if err != nil {
+		return 0, err
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for _, step := range []int64{current, current - 1, current + 1} {
+		expected, err := totp.GenerateCodeCustom(string(secret), time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    totpDigits,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidMFACode
+}
+
+func (s *mfaService) hashRecoveryCode(code string) string {
+	mac := hmac.New(sha256.New, s.recoveryKey)
+	mac.Write([]byte(strings.ReplaceAll(code, "-", "")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *mfaService) findUser(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.users.FindByID(ctx, userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// secretBinding ties an encrypted secret to its user, so that it cannot be
+// copied onto another account
+func secretBinding(userID uint) []byte {
+	return []byte("user:" + strconv.FormatUint(uint64(userID), 10))
+}
+
+// normalizeCode drops the spaces and case that users add when typing codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.Join(strings.Fields(code), ""))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits.Length() {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRecoveryCode returns a random code formatted as two groups of five
+// characters
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, recoveryCodeLength+1)
+	for i, b := range buf {
+		if i == recoveryCodeLength/2 {
+			code = append(code, '-')
+		}
+		code = append(code, recoveryCodeAlphabet[b%byte(len(recoveryCodeAlphabet))])
+	}
+	return string(code), nil
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/internal/testutil"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+	"github.com/pquerna/otp/totp"
+)
+
+const testPassword = "correct horse battery staple"
+
+// enrolledUser returns an MFA service and a user who has enabled MFA with
+// testPassword, with the user's TOTP secret and recovery codes
+func enrolledUser(t *testing.T) (*mfaService, *models.User, string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	users := repositories.NewUserRepository(db)
+
+	passwords, err := NewPasswordService(users, repositories.NewPasswordHistoryRepository(db),
+		config.Default().Security.Password)
+	if err != nil {
+		t.Fatalf("create password service: %v", err)
+	}
+	lockout := NewLockoutService(repositories.NewMemoryLoginAttemptRepository(), repositories.NewAuditRepository(db),
+		users, testLockoutConfig)
+	svc, err := NewMFAService(users, repositories.NewMFARepository(db), repositories.NewRefreshTokenRepository(db),
+		passwords, lockout, config.MFAConfig{
+			EncryptionKey: "Y2hhbmdlX21lX2NoYW5nZV9tZV9jaGFuZ2VfbWVfISE=",
+			Issuer:        "test",
+			ChallengeTTL:  time.Minute,
+		})
+	if err != nil {
+		t.Fatalf("create service: %v", err)
+	}
+
+	hash, err := passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: hash}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	enrollment, err := svc.Enroll(ctx, user.ID, testPassword, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	codes, err := svc.Confirm(ctx, user.ID, totpCode(t, enrollment.Secret, time.Now()))
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+
+	if user, err = users.FindByID(ctx, user.ID); err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	return svc.(*mfaService), user, enrollment.Secret, codes
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCode(secret, at)
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	return code
+}
+
+func TestTOTPStepCannotBeReplayed(t *testing.T) {
+	ctx := context.Background()
+	svc, user, secret, _ := enrolledUser(t)
+
+	// The code that confirmed the enrolment is used up
+	if err := svc.verifySecondFactor(ctx, user, totpCode(t, secret, time.Now())); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("confirmation code reused: error = %v, want ErrInvalidMFACode", err)
+	}
+
+	// The next step is accepted once, within the allowed drift
+	next := totpCode(t, secret, time.Now().Add(totpPeriod*time.Second))
+	if err := svc.verifySecondFactor(ctx, user, next); err != nil {
+		t.Fatalf("next step: %v", err)
+	}
+	if err := svc.verifySecondFactor(ctx, user, next); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("next step replayed: error = %v, want ErrInvalidMFACode", err)
+	}
+
+	// Earlier steps stay refused once a later one was used
+	previous := totpCode(t, secret, time.Now().Add(-totpPeriod*time.Second))
+	if err := svc.verifySecondFactor(ctx, user, previous); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("previous step: error = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	ctx := context.Background()
+	svc, user, _, codes := enrolledUser(t)
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// Codes are accepted however the user types them, but only once
+	if err := svc.verifySecondFactor(ctx, user, " "+strings.ToUpper(codes[0])+" "); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := svc.verifySecondFactor(ctx, user, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("second use: error = %v, want ErrInvalidMFACode", err)
+	}
+	if err := svc.verifySecondFactor(ctx, user, codes[1]); err != nil {
+		t.Errorf("another code: %v", err)
+	}
+	if err := svc.verifySecondFactor(ctx, user, "aaaaa-aaaaa"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("unknown code: error = %v, want ErrInvalidMFACode", err)
+	}
+
+	status, err := svc.Status(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if want := int64(recoveryCodeCount - 2); status.RecoveryCodesRemaining != want {
+		t.Errorf("remaining recovery codes = %d, want %d", status.RecoveryCodesRemaining, want)
+	}
+}
+
+func TestWrongPasswordOnDisableCountsTowardsLockout(t *testing.T) {
+	ctx := context.Background()
+	svc, user, _, codes := enrolledUser(t)
+
+	for i := 0; i <= testLockoutConfig.FreeAttempts; i++ {
+		err := svc.Disable(ctx, user.ID, "wrong password", codes[0], "192.0.2.1")
+		if !errors.Is(err, ErrIncorrectPassword) {
+			t.Fatalf("attempt %d: error = %v, want ErrIncorrectPassword", i+1, err)
+		}
+	}
+	// The right password is refused as well until the delay has passed
+	if err := svc.Disable(ctx, user.ID, testPassword, codes[0], "192.0.2.1"); !errors.Is(err, ErrLoginThrottled) {
+		t.Errorf("correct password: error = %v, want ErrLoginThrottled", err)
+	}
+}
+
+func TestMFAChallengeWorksOnce(t *testing.T) {
+	ctx := context.Background()
+	svc, user, _, codes := enrolledUser(t)
+
+	previousJWT := config.JWT
+	config.InitializeJWT(config.JWTConfig{Secret: "test-secret", Issuer: "test", AccessTokenTTL: time.Minute})
+	t.Cleanup(func() { config.InitializeJWT(previousJWT) })
+	auth := NewAuthService(svc.users, svc.tokens, svc.mfa, svc.passwords, svc.lockout, time.Minute)
+
+	login := func() string {
+		t.Helper()
+		result, err := auth.Login(ctx, user.Username, testPassword, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		return result.MFAToken
+	}
+
+	// A newer login replaces the challenge of an earlier one
+	replaced := login()
+	challenge := login()
+	if _, err := svc.CompleteLogin(ctx, replaced, codes[0], "192.0.2.1"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("replaced challenge: error = %v, want ErrInvalidMFAToken", err)
+	}
+
+	if _, err := svc.CompleteLogin(ctx, challenge, codes[0], "192.0.2.1"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := svc.CompleteLogin(ctx, challenge, codes[1], "192.0.2.1"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("second use: error = %v, want ErrInvalidMFAToken", err)
+	}
+
+	// A challenge token signed without an ID is refused outright
+	forged, err := utils.GenerateMFAChallengeToken(user.ID, "", time.Minute)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	if _, err := svc.CompleteLogin(ctx, forged, codes[1], "192.0.2.1"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Errorf("token without ID: error = %v, want ErrInvalidMFAToken", err)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/mail"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+	"github.com/canhbk/golang-gin-starter-kit/utils"
+)
+
+var ErrInvalidResetToken = apperror.BadRequest("invalid_reset_token", "Password reset token is invalid or expired")
+
+// PasswordResetService lets users who forgot their password set a new one
+// through a single-use link sent to their email address
+type PasswordResetService interface {
+	// Forgot is rate-limited per address and per client IP
+	Forgot(ctx context.Context, email, ip string) error
+	Reset(ctx context.Context, token, password string) error
+}
+
+type passwordResetService struct {
+	users      repositories.UserRepository
+	tokens     repositories.PasswordResetRepository
+	passwords  PasswordService
+	lockout    LockoutService
+	mailer     mail.Sender
+	background *Background
+	cfg        config.PasswordResetConfig
+}
+
+func NewPasswordResetService(users repositories.UserRepository, tokens repositories.PasswordResetRepository,
+	passwords PasswordService, lockout LockoutService, mailer mail.Sender, background *Background,
+	cfg config.PasswordResetConfig) PasswordResetService {
+	return &passwordResetService{
+		users:      users,
+		tokens:     tokens,
+		passwords:  passwords,
+		lockout:    lockout,
+		mailer:     mailer,
+		background: background,
+		cfg:        cfg,
+	}
+}
+
+// Forgot emails a reset link when email belongs to a user, and succeeds
+// silently otherwise. Both paths do the same work within the request, the
+// rate limit and a single lookup: the token is created and mailed in the
+// background, so that neither the outcome nor the timing reveals which
+// addresses are registered. Failures past the lookup are logged.
+func (s *passwordResetService) Forgot(ctx context.Context, email, ip string) error {
+	err := s.lockout.Limit(ctx, "reset", email, ip, s.cfg.MaxPerEmail, s.cfg.MaxPerIP)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.FindByEmail(ctx, email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	bgCtx := context.WithoutCancel(ctx)
+	s.background.Go(func() {
+		if err := s.sendReset(bgCtx, user); err != nil {
+			logging.FromContext(bgCtx).Error("failed to send password reset email",
+				"user_id", user.ID, "error", err)
+		}
+	})
+	return nil
+}
+
+// sendReset stores a new reset token for user and mails the link
+func (s *passwordResetService) sendReset(ctx context.Context, user *models.User) error {
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = s.tokens.Create(ctx, &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.cfg.TokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, s.resetMessage(user, token))
+}
+
+// Reset sets a new password if token is valid. The token is used up, and
+// every session of the user is revoked.
+func (s *passwordResetService) Reset(ctx context.Context, token, password string) error {
+	record, err := s.tokens.FindByHash(ctx, utils.HashToken(token))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if !record.IsActive(time.Now()) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.users.FindByID(ctx, record.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.passwords.Validate(ctx, user, password); err != nil {
+		return err
+	}
+	hashedPassword, err := s.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	err = s.tokens.Redeem(ctx, record, hashedPassword)
+	if errors.Is(err, repositories.ErrTokenUsed) || errors.Is(err, repositories.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	retirePassword(ctx, s.passwords, user.ID, user.Password)
+	return nil
+}
+
+func (s *passwordResetService) resetMessage(user *models.User, token string) mail.Message {
+	link, _ := url.Parse(s.cfg.URL)
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We received a request to reset your password. Open the link below to choose a new one:\n\n"+
+			"%s\n\n"+
+			"The link expires in %s and can be used once. If you did not ask for a reset, ignore this email.\n",
+			user.Username, link, humanDuration(s.cfg.TokenTTL)),
+	}
+}
+
+// humanDuration spells out d in whole hours or minutes for use in emails
+func humanDuration(d time.Duration) string {
+	unit, n := "minute", int64(d.Round(time.Minute)/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int64(d/time.Hour)
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/password"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+// PasswordService applies the password policy, and hashes and verifies
+// passwords with the configured algorithm
+type PasswordService interface {
+	// Validate checks password against the policy for user, who has not been
+	// saved yet when user.ID is zero. Saved users may not reuse their recent
+	// passwords either.
+	Validate(ctx context.Context, user *models.User, password string) error
+	Hash(password string) (string, error)
+	// Verify reports whether password is user's. A nil user is compared
+	// against a dummy hash, so that unknown users take as long to reject.
+	// Matching hashes made with outdated settings are replaced.
+	Verify(ctx context.Context, user *models.User, password string) (bool, error)
+	// Retire adds the hash a user just changed away from to their history
+	Retire(ctx context.Context, userID uint, hash string) error
+}
+
+type passwordService struct {
+	users   repositories.UserRepository
+	history repositories.PasswordHistoryRepository
+	hasher  password.Hasher
+	policy  *password.Policy
+	// historySize counts the current password, which is not in the history
+	historySize int
+	dummyHash   string
+}
+
+func NewPasswordService(users repositories.UserRepository, history repositories.PasswordHistoryRepository,
+	cfg config.PasswordConfig) (PasswordService, error) {
+	hasher, err := password.NewHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := password.NewPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dummyHash, err := hasher.Hash("dummy-password")
+	if err != nil {
+		return nil, err
+	}
+
+	return &passwordService{
+		users:       users,
+		history:     history,
+		hasher:      hasher,
+		policy:      policy,
+		historySize: cfg.History,
+		dummyHash:   dummyHash,
+	}, nil
+}
+
+func (s *passwordService) Validate(ctx context.Context, user *models.User, pw string) error {
+	if violations := s.policy.Check(pw, user.Username, user.Email); len(violations) > 0 {
+		return policyViolation(violations...)
+	}
+	if user.ID == 0 || s.historySize == 0 {
+		return nil
+	}
+
+	// Checked last, since every comparison costs a full hash
+	previous := []string{user.Password}
+	if s.historySize > 1 {
+		retired, err := s.history.Recent(ctx, user.ID, s.historySize-1)
+		if err != nil {
+			return err
+		}
+		previous = append(previous, retired...)
+	}
+	for _, hash := range previous {
+		match, _, err := s.hasher.Verify(pw, hash)
+		if err != nil {
+			return err
+		}
+		if match {
+			return policyViolation(apperror.FieldError{
+				Field:   password.Field,
+				Code:    "reused",
+				Message: fmt.Sprintf("must not be one of the last %d passwords", s.historySize),
+			})
+		}
+	}
+	return nil
+}
+
+func (s *passwordService) Hash(pw string) (string, error) {
+	return s.hasher.Hash(pw)
+}
+
+func (s *passwordService) Verify(ctx context.Context, user *models.User, pw string) (bool, error) {
+	if user == nil {
+		_, _, _ = s.hasher.Verify(pw, s.dummyHash)
+		return false, nil
+	}
+
+	match, rehash, err := s.hasher.Verify(pw, user.Password)
+	if err != nil || !match {
+		return false, err
+	}
+	if rehash {
+		s.upgrade(ctx, user, pw)
+	}
+	return true, nil
+}
+
+func (s *passwordService) Retire(ctx context.Context, userID uint, hash string) error {
+	if s.historySize <= 1 {
+		return nil
+	}
+	return s.history.Add(ctx, userID, hash, s.historySize-1)
+}
+
+// upgrade rehashes a correct password with the current settings. The login
+// has already succeeded, so failures are only logged and retried next time.
+func (s *passwordService) upgrade(ctx context.Context, user *models.User, pw string) {
+	hash, err := s.hasher.Hash(pw)
+	var replaced bool
+	if err == nil {
+		replaced, err = s.users.ReplacePasswordHash(ctx, user.ID, user.Password, hash)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to upgrade password hash", "user_id", user.ID, "error", err)
+		return
+	}
+	if replaced {
+		user.Password = hash
+	}
+}
+
+func policyViolation(violations ...apperror.FieldError) error {
+	return apperror.Validation("The password does not meet the password policy", violations...)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+var ErrMFARequired = apperror.Forbidden("mfa_required",
+	"Your role requires multi-factor authentication. Enable it to use your permissions.")
+
+// PermissionService resolves the permissions a user may exercise. It
+// satisfies middleware.PermissionLoader.
+type PermissionService interface {
+	// PermissionsForUser returns the user's permissions, or ErrMFARequired
+	// when one of their roles requires MFA and they have not enabled it
+	PermissionsForUser(ctx context.Context, userID uint) ([]string, error)
+}
+
+type permissionService struct {
+	roles    repositories.RoleRepository
+	users    repositories.UserRepository
+	mfaRoles []string
+}
+
+// NewPermissionService withholds the permissions of members of mfaRoles
+// until they enable MFA. They can still log in and reach the routes that
+// need no permission, which includes enrolling in MFA.
+func NewPermissionService(roles repositories.RoleRepository, users repositories.UserRepository,
+	mfaRoles []string) PermissionService {
+	return &permissionService{roles: roles, users: users, mfaRoles: mfaRoles}
+}
+
+func (s *permissionService) PermissionsForUser(ctx context.Context, userID uint) ([]string, error) {
+	if len(s.mfaRoles) > 0 {
+		roles, err := s.roles.RolesForUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(roles, func(role string) bool { return slices.Contains(s.mfaRoles, role) }) {
+			user, err := s.users.FindByID(ctx, userID)
+			if errors.Is(err, repositories.ErrNotFound) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if user.MFAEnabledAt == nil {
+				return nil, ErrMFARequired
+			}
+		}
+	}
+	return s.roles.PermissionsForUser(ctx, userID)
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+var (
+	ErrServiceAccountNotFound  = apperror.NotFound("service_account_not_found", "No service account exists with the provided ID")
+	ErrServiceAccountNameTaken = apperror.Conflict("service_account_name_taken", "Service account name is already taken").WithFields(takenField("name"))
+)
+
+// UpdateServiceAccountInput lists the changes to a service account; nil
+// fields are left as they are
+type UpdateServiceAccountInput struct {
+	Name        *string
+	Description *string
+}
+
+// ServiceAccountService manages the service accounts that own API keys
+type ServiceAccountService interface {
+	Create(ctx context.Context, actorID uint, name, description string) (*models.ServiceAccount, error)
+	List(ctx context.Context) ([]models.ServiceAccount, error)
+	Get(ctx context.Context, id uint) (*models.ServiceAccount, error)
+	Update(ctx context.Context, id uint, input UpdateServiceAccountInput) (*models.ServiceAccount, error)
+	// Delete removes the account and revokes all of its keys
+	Delete(ctx context.Context, id uint) error
+}
+
+type serviceAccountService struct {
+	accounts repositories.ServiceAccountRepository
+}
+
+func NewServiceAccountService(accounts repositories.ServiceAccountRepository) ServiceAccountService {
+	return &serviceAccountService{accounts: accounts}
+}
+
+func (s *serviceAccountService) Create(ctx context.Context, actorID uint, name, description string) (*models.ServiceAccount, error) {
+	account := &models.ServiceAccount{Name: name, Description: description, CreatedByID: &actorID}
+	if err := s.accounts.Create(ctx, account); err != nil {
+		return nil, translateServiceAccountDuplicate(err)
+	}
+	return account, nil
+}
+
+func (s *serviceAccountService) List(ctx context.Context) ([]models.ServiceAccount, error) {
+	return s.accounts.List(ctx)
+}
+
+func (s *serviceAccountService) Get(ctx context.Context, id uint) (*models.ServiceAccount, error) {
+	account, err := s.accounts.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrServiceAccountNotFound
+	}
+	return account, err
+}
+
+func (s *serviceAccountService) Update(ctx context.Context, id uint, input UpdateServiceAccountInput) (*models.ServiceAccount, error) {
+	account, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	if input.Name != nil {
+		account.Name = *input.Name
+		columns = append(columns, "name")
+	}
+	if input.Description != nil {
+		account.Description = *input.Description
+		columns = append(columns, "description")
+	}
+	if len(columns) == 0 {
+		return account, nil
+	}
+
+	if err := s.accounts.Update(ctx, account, columns...); err != nil {
+		return nil, translateServiceAccountDuplicate(err)
+	}
+	return account, nil
+}
+
+func (s *serviceAccountService) Delete(ctx context.Context, id uint) error {
+	err := s.accounts.Delete(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrServiceAccountNotFound
+	}
+	return err
+}
+
+// translateServiceAccountDuplicate turns a unique index violation into the
+// taken name conflict; name is the only unique column
+func translateServiceAccountDuplicate(err error) error {
+	var dup *repositories.DuplicateError
+	if errors.As(err, &dup) {
+		return ErrServiceAccountNameTaken.Wrap(err)
+	}
+	return err
+}
@@ -0,0 +1,627 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/apperror"
+	"github.com/canhbk/golang-gin-starter-kit/logging"
+	"github.com/canhbk/golang-gin-starter-kit/models"
+	"github.com/canhbk/golang-gin-starter-kit/repositories"
+)
+
+var (
+	ErrUserNotFound        = apperror.NotFound("user_not_found", "No user exists with the provided ID")
+	ErrDeletedUserNotFound = apperror.NotFound("deleted_user_not_found", "No deleted user exists with the provided ID")
+	ErrVersionMismatch     = apperror.PreconditionFailed(apperror.CodePrecondition, "The user has been modified since the given version")
+	ErrEditConflict        = apperror.Conflict("edit_conflict", "The user was modified by another request, retry with the latest version")
+	ErrUsernameTaken       = apperror.Conflict("username_taken", "Username is already taken").WithFields(takenField("username"))
+	ErrEmailTaken          = apperror.Conflict("email_taken", "Email is already taken").WithFields(takenField("email"))
+
+	ErrCurrentPasswordRequired = apperror.Validation("Confirm the change with your current password",
+		apperror.FieldError{Field: "current_password", Code: "required", Message: "is required"})
+	ErrIncorrectCurrentPassword = apperror.Validation("The current password is incorrect",
+		apperror.FieldError{Field: "current_password", Code: "incorrect", Message: "is incorrect"})
+)
+
+func takenField(field string) apperror.FieldError {
+	return apperror.FieldError{Field: field, Code: "taken", Message: "is already taken"}
+}
+
+// CreateUserInput holds the fields required to create a user
+type CreateUserInput struct {
+	Username string
+	Email    string
+	Password string
+}
+
+// UpdateUserInput holds the fields to change on a user. Nil fields are left
+// untouched. A non-zero ExpectedVersion makes the update conditional on it.
+//
+// ActorID is the user making the change. Users changing their own password
+// or email must confirm it with CurrentPassword, so that a stolen access
+// token is not enough to take over the account.
+type UpdateUserInput struct {
+	Username        *string
+	Email           *string
+	Password        *string
+	CurrentPassword *string
+	ExpectedVersion uint
+	ActorID         uint
+}
+
+// exportBatchSize is how many users Export reads per query
+const exportBatchSize = 500
+
+// Page size bounds for user listings
+const (
+	DefaultUsersPerPage = 10
+	MaxUsersPerPage     = 100
+)
+
+// ListUsersInput selects a page of users. Sort is a comma separated list of
+// sortable fields, each optionally prefixed with '-' for descending order.
+//
+// With UseCursor set the listing pages by keyset on (created_at, id) instead
+// of by offset: Cursor is empty for the first page and then the NextCursor of
+// the previous page, and Sort may only be created_at or -created_at.
+type ListUsersInput struct {
+	Page          int
+	PerPage       int
+	UseCursor     bool
+	Cursor        string
+	IncludeTotal  bool
+	Search        string
+	Email         string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Sort          string
+}
+
+// UserPage is one page of a user listing. Total is only set when requested,
+// and NextCursor is empty on the last page or in offset mode.
+type UserPage struct {
+	Users      []models.User
+	Total      *int64
+	PerPage    int
+	NextCursor string
+}
+
+// userSortColumns whitelists the fields users can be sorted by
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// UserService holds the business rules for managing users
+type UserService interface {
+	Create(ctx context.Context, input CreateUserInput) (*models.User, error)
+	Get(ctx context.Context, id uint) (*models.User, error)
+	List(ctx context.Context, input ListUsersInput) (*UserPage, error)
+	Update(ctx context.Context, id uint, input UpdateUserInput) (*models.User, error)
+	Delete(ctx context.Context, id uint, expectedVersion uint) error
+	ListDeleted(ctx context.Context, page, perPage int) (*UserPage, error)
+	Restore(ctx context.Context, id uint) (*models.User, error)
+	Purge(ctx context.Context, id uint) error
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	ImportBatch(ctx context.Context, inputs []CreateUserInput) ([]ImportResult, error)
+	Export(ctx context.Context, fn func(users []models.User) error) error
+}
+
+// ImportResult is the outcome of importing one user: either the created
+// User or the reason it was rejected
+type ImportResult struct {
+	User *models.User
+	Err  error
+}
+
+type userService struct {
+	users     repositories.UserRepository
+	passwords PasswordService
+	verifier  EmailVerificationService
+}
+
+// NewUserService builds the user service. verifier sends verification
+// emails after signups and email changes; it may be nil where no mail should
+// go out, such as in the database CLI.
+func NewUserService(users repositories.UserRepository, passwords PasswordService,
+	verifier EmailVerificationService) UserService {
+	return &userService{users: users, passwords: passwords, verifier: verifier}
+}
+
+func (s *userService) Create(ctx context.Context, input CreateUserInput) (*models.User, error) {
+	if err := s.checkUsernameAvailable(ctx, input.Username); err != nil {
+		return nil, err
+	}
+	if err := s.checkEmailAvailable(ctx, input.Email); err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username: input.Username,
+		Email:    input.Email,
+		Version:  1,
+	}
+	if err := s.passwords.Validate(ctx, user, input.Password); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.passwords.Hash(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hashedPassword
+
+	if err := s.users.Create(ctx, user); err != nil {
+		return nil, translateDuplicate(err)
+	}
+	s.sendVerification(ctx, user)
+	return user, nil
+}
+
+func (s *userService) Get(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.users.FindByID(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *userService) List(ctx context.Context, input ListUsersInput) (*UserPage, error) {
+	perPage, err := validatePaging(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repositories.UserFilter{
+		Search:        strings.TrimSpace(input.Search),
+		Email:         input.Email,
+		CreatedAfter:  input.CreatedAfter,
+		CreatedBefore: input.CreatedBefore,
+	}
+
+	page := &UserPage{PerPage: perPage}
+	if input.UseCursor {
+		if err := s.listByCursor(ctx, input, filter, page); err != nil {
+			return nil, err
+		}
+	} else {
+		order, err := parseUserSort(input.Sort)
+		if err != nil {
+			return nil, err
+		}
+		offset := (input.Page - 1) * perPage
+		if page.Users, err = s.users.List(ctx, filter, order, offset, perPage); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.IncludeTotal {
+		total, err := s.users.Count(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+	return page, nil
+}
+
+// listByCursor fetches one row past the page to learn whether another page
+// follows, and hands out a cursor for it only if so
+func (s *userService) listByCursor(ctx context.Context, input ListUsersInput, filter repositories.UserFilter, page *UserPage) error {
+	var after *repositories.UserKey
+	desc, err := parseCursorSort(input.Sort)
+	if err != nil {
+		return err
+	}
+	if input.Cursor != "" {
+		cursor, err := decodeUserCursor(input.Cursor)
+		if err != nil {
+			return invalidListParam("cursor", "invalid", "is not a valid cursor")
+		}
+		if input.Sort != "" && cursor.Desc != desc {
+			return invalidListParam("sort", "cursor_mismatch", "must match the sort order the cursor was issued for")
+		}
+		desc = cursor.Desc
+		after = cursor.key()
+	}
+
+	users, err := s.users.ListAfter(ctx, filter, after, desc, page.PerPage+1)
+	if err != nil {
+		return err
+	}
+	if len(users) > page.PerPage {
+		users = users[:page.PerPage]
+		last := users[len(users)-1]
+		page.NextCursor = encodeUserCursor(userCursor{CreatedAt: last.CreatedAt, ID: last.ID, Desc: desc})
+	}
+	page.Users = users
+	return nil
+}
+
+// validatePaging checks the page parameters and returns the page size,
+// capped at MaxUsersPerPage
+func validatePaging(input *ListUsersInput) (int, error) {
+	if input.PerPage < 1 {
+		return 0, invalidListParam("per_page", "min", "must be at least 1")
+	}
+	if input.UseCursor && input.Page != 0 {
+		return 0, invalidListParam("page", "conflict", "cannot be combined with cursor")
+	}
+	if !input.UseCursor && input.Page < 1 {
+		return 0, invalidListParam("page", "min", "must be at least 1")
+	}
+	return min(input.PerPage, MaxUsersPerPage), nil
+}
+
+// parseCursorSort accepts the only orderings keyset paging supports
+func parseCursorSort(sort string) (bool, error) {
+	switch strings.TrimSpace(sort) {
+	case "", "created_at":
+		return false, nil
+	case "-created_at":
+		return true, nil
+	default:
+		return false, invalidListParam("sort", "unsupported", "must be created_at or -created_at when paging by cursor")
+	}
+}
+
+func invalidListParam(field, code, message string) error {
+	return apperror.Validation("The request contains invalid fields", apperror.FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	})
+}
+
+// parseUserSort turns "-created_at,username" into an ordering, rejecting
+// fields that are not whitelisted
+func parseUserSort(sort string) ([]repositories.OrderBy, error) {
+	if sort == "" {
+		return nil, nil
+	}
+
+	var order []repositories.OrderBy
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		column, ok := userSortColumns[strings.TrimPrefix(field, "-")]
+		if !ok {
+			return nil, invalidListParam("sort", "unknown_field", fmt.Sprintf("cannot sort by %q", field))
+		}
+		order = append(order, repositories.OrderBy{Column: column, Desc: desc})
+	}
+	return order, nil
+}
+
+func (s *userService) Update(ctx context.Context, id uint, input UpdateUserInput) (*models.User, error) {
+	user, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Fail fast on a stale version; the conditional write below still
+	// guards against writes that land after this read
+	if input.ExpectedVersion != 0 && input.ExpectedVersion != user.Version {
+		return nil, ErrVersionMismatch
+	}
+
+	// Track the columns that actually change so that only those are written
+	var columns []string
+	if input.Username != nil && *input.Username != user.Username {
+		if err := s.checkUsernameAvailable(ctx, *input.Username); err != nil {
+			return nil, err
+		}
+		user.Username = *input.Username
+		columns = append(columns, "username")
+	}
+	previousEmail := user.Email
+	if input.Email != nil && *input.Email != user.Email {
+		if input.ActorID == user.ID {
+			if err := s.confirmPassword(ctx, user, input.CurrentPassword); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.checkEmailAvailable(ctx, *input.Email); err != nil {
+			return nil, err
+		}
+		// The new address has to be confirmed again
+		user.Email = *input.Email
+		user.EmailVerifiedAt = nil
+		columns = append(columns, "email", "email_verified_at")
+	}
+	// Validated after the username and email, which it must not repeat
+	var retiredHash string
+	if input.Password != nil {
+		// An email change in the same request has already confirmed it
+		if input.ActorID == user.ID && !slices.Contains(columns, "email") {
+			if err := s.confirmPassword(ctx, user, input.CurrentPassword); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.passwords.Validate(ctx, user, *input.Password); err != nil {
+			return nil, err
+		}
+		hashedPassword, err := s.passwords.Hash(*input.Password)
+		if err != nil {
+			return nil, err
+		}
+		retiredHash = user.Password
+		user.Password = hashedPassword
+		columns = append(columns, "password")
+	}
+	if len(columns) == 0 {
+		return user, nil
+	}
+
+	// A new password ends every session, like a reset does
+	write := s.users.Update
+	if retiredHash != "" {
+		write = s.users.UpdateAndRevokeSessions
+	}
+	err = write(ctx, user, columns...)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		return nil, versionConflict(input.ExpectedVersion)
+	}
+	if err != nil {
+		return nil, translateDuplicate(err)
+	}
+	if user.EmailVerifiedAt == nil && slices.Contains(columns, "email") {
+		s.sendVerification(ctx, user)
+		if s.verifier != nil {
+			s.verifier.NotifyEmailChanged(ctx, user, previousEmail)
+		}
+	}
+	if retiredHash != "" {
+		retirePassword(ctx, s.passwords, user.ID, retiredHash)
+	}
+	return user, nil
+}
+
+// confirmPassword checks the current password a user gave to approve a
+// sensitive change to their own account
+func (s *userService) confirmPassword(ctx context.Context, user *models.User, current *string) error {
+	if current == nil || *current == "" {
+		return ErrCurrentPasswordRequired
+	}
+	match, err := s.passwords.Verify(ctx, user, *current)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return ErrIncorrectCurrentPassword
+	}
+	return nil
+}
+
+// retirePassword records a replaced password hash. The new password is
+// already saved, so a failure only weakens the reuse check and is logged.
+func retirePassword(ctx context.Context, passwords PasswordService, userID uint, hash string) {
+	if err := passwords.Retire(ctx, userID, hash); err != nil {
+		logging.FromContext(ctx).Error("failed to record password history", "user_id", userID, "error", err)
+	}
+}
+
+// sendVerification emails a verification link to a new or changed address.
+// The user has been saved at this point, so a failure is only logged; the
+// user can ask for another link.
+func (s *userService) sendVerification(ctx context.Context, user *models.User) {
+	if s.verifier == nil {
+		return
+	}
+	if err := s.verifier.SendVerification(ctx, user); err != nil {
+		logging.FromContext(ctx).Error("failed to send verification email", "user_id", user.ID, "error", err)
+	}
+}
+
+func (s *userService) Delete(ctx context.Context, id uint, expectedVersion uint) error {
+	err := s.users.Delete(ctx, id, expectedVersion)
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return ErrUserNotFound
+	case errors.Is(err, repositories.ErrVersionConflict):
+		return versionConflict(expectedVersion)
+	}
+	return err
+}
+
+// versionConflict reports a lost race as a failed precondition when the
+// client asked for a version, and as an edit conflict otherwise
+func versionConflict(expectedVersion uint) error {
+	if expectedVersion != 0 {
+		return ErrVersionMismatch
+	}
+	return ErrEditConflict
+}
+
+func (s *userService) ListDeleted(ctx context.Context, page, perPage int) (*UserPage, error) {
+	perPage, err := validatePaging(&ListUsersInput{Page: page, PerPage: perPage})
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.users.ListDeleted(ctx, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, err
+	}
+	return &UserPage{Users: users, Total: &total, PerPage: perPage}, nil
+}
+
+// Restore undeletes a soft-deleted user. Deleted users release their
+// username and email, so restoring fails with ErrUsernameTaken or
+// ErrEmailTaken when another user has taken either since.
+func (s *userService) Restore(ctx context.Context, id uint) (*models.User, error) {
+	err := s.users.Restore(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrDeletedUserNotFound
+	}
+	if err != nil {
+		return nil, translateDuplicate(err)
+	}
+	return s.Get(ctx, id)
+}
+
+// Purge permanently removes a user that has already been soft-deleted
+func (s *userService) Purge(ctx context.Context, id uint) error {
+	err := s.users.Purge(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrDeletedUserNotFound
+	}
+	return err
+}
+
+func (s *userService) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.users.PurgeDeletedBefore(ctx, cutoff)
+}
+
+// ImportBatch creates users in one transaction. Rows that clash with an
+// existing user or with an earlier row of the batch are rejected up front;
+// the rest are inserted together. The returned error is reserved for
+// failures that affect the whole batch.
+func (s *userService) ImportBatch(ctx context.Context, inputs []CreateUserInput) ([]ImportResult, error) {
+	results := make([]ImportResult, len(inputs))
+	usernames := make([]string, len(inputs))
+	emails := make([]string, len(inputs))
+	for i, input := range inputs {
+		usernames[i], emails[i] = input.Username, input.Email
+	}
+
+	takenUsernames, takenEmails, err := s.users.FindTaken(ctx, usernames, emails)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []int
+	for i, input := range inputs {
+		candidate := &models.User{Username: input.Username, Email: input.Email}
+		if err := s.passwords.Validate(ctx, candidate, input.Password); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		switch {
+		case takenUsernames[input.Username]:
+			results[i].Err = ErrUsernameTaken
+		case takenEmails[input.Email]:
+			results[i].Err = ErrEmailTaken
+		default:
+			// Claim the values so that later rows in the batch cannot reuse them
+			takenUsernames[input.Username] = true
+			takenEmails[input.Email] = true
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	users, err := s.hashImportedPasswords(inputs, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.users.CreateBatch(ctx, users)
+	var dup *repositories.DuplicateError
+	if errors.As(err, &dup) {
+		// Another request took a value after the check; retry row by row
+		// so that only the clashing rows fail
+		for n, i := range pending {
+			users[n].ID = 0
+			if err := s.users.Create(ctx, users[n]); err != nil {
+				results[i].Err = translateDuplicate(err)
+				continue
+			}
+			results[i].User = users[n]
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for n, i := range pending {
+		results[i].User = users[n]
+	}
+	return results, nil
+}
+
+// hashImportedPasswords builds the users for the pending rows, hashing their
+// passwords in parallel since hashing dominates the cost of an import
+func (s *userService) hashImportedPasswords(inputs []CreateUserInput, pending []int) ([]*models.User, error) {
+	users := make([]*models.User, len(pending))
+	errs := make([]error, len(pending))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+
+	var wg sync.WaitGroup
+	for n, i := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			hashedPassword, err := s.passwords.Hash(inputs[i].Password)
+			users[n] = &models.User{
+				Username: inputs[i].Username,
+				Email:    inputs[i].Email,
+				Password: hashedPassword,
+				Version:  1,
+			}
+			errs[n] = err
+		}()
+	}
+	wg.Wait()
+	return users, errors.Join(errs...)
+}
+
+// Export passes every user to fn in batches, in primary key order
+func (s *userService) Export(ctx context.Context, fn func(users []models.User) error) error {
+	return s.users.Each(ctx, exportBatchSize, fn)
+}
+
+func (s *userService) checkUsernameAvailable(ctx context.Context, username string) error {
+	taken, err := s.users.ExistsByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+func (s *userService) checkEmailAvailable(ctx context.Context, email string) error {
+	taken, err := s.users.ExistsByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// translateDuplicate turns a unique index violation into the matching
+// conflict error. The pre-checks catch most duplicates; this covers writes
+// that race with another request.
+func translateDuplicate(err error) error {
+	var dup *repositories.DuplicateError
+	if !errors.As(err, &dup) {
+		return err
+	}
+	switch dup.Column {
+	case "username":
+		return ErrUsernameTaken.Wrap(err)
+	case "email":
+		return ErrEmailTaken.Wrap(err)
+	default:
+		return apperror.Conflict(apperror.CodeConflict, "A user with the same unique value already exists").Wrap(err)
+	}
+}
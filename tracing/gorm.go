@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey stores the in-flight span on the statement between callbacks
+const spanKey = "tracing:span"
+
+// Attribute keys from the OpenTelemetry database semantic conventions
+const (
+	attrDBSystem       = attribute.Key("db.system")
+	attrDBStatement    = attribute.Key("db.statement")
+	attrDBOperation    = attribute.Key("db.operation")
+	attrDBTable        = attribute.Key("db.sql.table")
+	attrDBRowsAffected = attribute.Key("db.rows_affected")
+)
+
+// GormPlugin creates a child span for every statement GORM executes. The
+// parent comes from the statement context, so queries must be issued with
+// db.WithContext(ctx) to be attached to the request span. Statements are
+// recorded with placeholders; bound values are never exported.
+type GormPlugin struct {
+	tracer trace.Tracer
+}
+
+func NewGormPlugin() *GormPlugin {
+	return &GormPlugin{tracer: otel.Tracer(instrumentationName)}
+}
+
+func (p *GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	register := []struct {
+		name      string
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}{
+		{"create", "INSERT",
+			callbacks.Create().Before("gorm:create").Register,
+			callbacks.Create().After("gorm:create").Register},
+		{"query", "SELECT",
+			callbacks.Query().Before("gorm:query").Register,
+			callbacks.Query().After("gorm:query").Register},
+		{"update", "UPDATE",
+			callbacks.Update().Before("gorm:update").Register,
+			callbacks.Update().After("gorm:update").Register},
+		{"delete", "DELETE",
+			callbacks.Delete().Before("gorm:delete").Register,
+			callbacks.Delete().After("gorm:delete").Register},
+		{"row", "ROW",
+			callbacks.Row().Before("gorm:row").Register,
+			callbacks.Row().After("gorm:row").Register},
+		{"raw", "RAW",
+			callbacks.Raw().Before("gorm:raw").Register,
+			callbacks.Raw().After("gorm:raw").Register},
+	}
+	for _, r := range register {
+		if err := r.before("tracing:before_"+r.name, p.before(r.operation)); err != nil {
+			return err
+		}
+		if err := r.after("tracing:after_"+r.name, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			return
+		}
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		_, span := p.tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attrDBSystem.String(db.Dialector.Name()),
+				attrDBOperation.String(operation),
+			),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func (p *GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		attrDBStatement.String(db.Statement.SQL.String()),
+		attrDBTable.String(db.Statement.Table),
+		attrDBRowsAffected.Int64(db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
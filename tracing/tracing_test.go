@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+// TestRequestSpans sends one request with a traceparent header through a
+// router set up like main.go and checks that the server span continues the
+// caller's trace and that the query becomes its child.
+func TestRequestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	if _, err := Setup(context.Background(), config.TracingConfig{Exporter: config.TracingExporterNone}); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&widget{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Use(NewGormPlugin()); err != nil {
+		t.Fatalf("register plugin: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware("test"))
+	router.GET("/widgets/:id", func(c *gin.Context) {
+		var w widget
+		err := db.WithContext(c.Request.Context()).Where("name = ?", "secret").First(&w).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/widgets/1", nil)
+	req.Header.Set("traceparent", "00-"+testTraceID+"-"+testSpanID+"-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	spans := exporter.GetSpans()
+	var server, query *tracetest.SpanStub
+	for i := range spans {
+		switch spans[i].SpanKind {
+		case trace.SpanKindServer:
+			server = &spans[i]
+		case trace.SpanKindClient:
+			query = &spans[i]
+		}
+	}
+	if server == nil || query == nil {
+		t.Fatalf("got spans %v, want a server and a client span", spanNames(spans))
+	}
+
+	if server.Name != "/widgets/:id" {
+		t.Errorf("server span name = %q, want the route", server.Name)
+	}
+	if got := server.SpanContext.TraceID().String(); got != testTraceID {
+		t.Errorf("server span trace ID = %s, want the one from traceparent %s", got, testTraceID)
+	}
+	if got := server.Parent.SpanID().String(); got != testSpanID || !server.Parent.IsRemote() {
+		t.Errorf("server span parent = %s (remote %t), want remote %s", got, server.Parent.IsRemote(), testSpanID)
+	}
+
+	if query.Name != "SELECT widgets" {
+		t.Errorf("query span name = %q, want %q", query.Name, "SELECT widgets")
+	}
+	if query.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("query span parent = %s, want the server span %s", query.Parent.SpanID(), server.SpanContext.SpanID())
+	}
+	attrs := attributeMap(query.Attributes)
+	if got := attrs[attrDBTable]; got != "widgets" {
+		t.Errorf("%s = %q, want widgets", attrDBTable, got)
+	}
+	statement := attrs[attrDBStatement]
+	if !strings.HasPrefix(statement, "SELECT * FROM `widgets` WHERE name = ?") {
+		t.Errorf("%s = %q, want the query with placeholders", attrDBStatement, statement)
+	}
+	if strings.Contains(statement, "secret") {
+		t.Errorf("%s = %q exposes a bound value", attrDBStatement, statement)
+	}
+}
+
+func attributeMap(attrs []attribute.KeyValue) map[attribute.Key]string {
+	m := make(map[attribute.Key]string, len(attrs))
+	for _, attr := range attrs {
+		m[attr.Key] = attr.Value.Emit()
+	}
+	return m
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	return names
+}
@@ -0,0 +1,34 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+)
+
+type CreateRequest struct {
+	Name   string   `json:"name" binding:"required,max=100" example:"nightly-export"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,required" example:"users:list"`
+	// ExpiresAt defaults to the configured key lifetime when omitted
+	ExpiresAt *time.Time `json:"expires_at" example:"2027-01-01T00:00:00Z"`
+}
+
+// PatchRequest is a JSON Merge Patch of a key. Absent members are left
+// untouched; none of them can be removed.
+type PatchRequest struct {
+	Name      common.PatchField[string]    `json:"name" swaggertype:"string" example:"nightly-export"`
+	Scopes    common.PatchField[[]string]  `json:"scopes" swaggertype:"array,string" example:"users:list"`
+	ExpiresAt common.PatchField[time.Time] `json:"expires_at" swaggertype:"string" example:"2027-01-01T00:00:00Z"`
+}
+
+type ServiceAccountCreateRequest struct {
+	Name        string `json:"name" binding:"required,max=100" example:"billing-batch"`
+	Description string `json:"description" binding:"max=255" example:"Nightly billing export"`
+}
+
+// ServiceAccountPatchRequest is a JSON Merge Patch of a service account. A
+// null description clears it.
+type ServiceAccountPatchRequest struct {
+	Name        common.PatchField[string] `json:"name" swaggertype:"string" example:"billing-batch"`
+	Description common.PatchField[string] `json:"description" swaggertype:"string" example:"Nightly billing export"`
+}
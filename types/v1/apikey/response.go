@@ -0,0 +1,39 @@
+package apikey
+
+import "time"
+
+type Response struct {
+	ID               uint       `json:"id" example:"1"`
+	Name             string     `json:"name" example:"nightly-export"`
+	Prefix           string     `json:"prefix" example:"sk_3f9a1c2b7d4e"`
+	Scopes           []string   `json:"scopes" example:"users:list"`
+	UserID           *uint      `json:"user_id,omitempty" example:"1"`
+	ServiceAccountID *uint      `json:"service_account_id,omitempty" example:"1"`
+	ExpiresAt        time.Time  `json:"expires_at" example:"2027-01-01T00:00:00Z"`
+	LastUsedAt       *time.Time `json:"last_used_at" example:"2026-10-18T12:34:56Z"`
+	CreatedAt        time.Time  `json:"created_at" example:"2026-10-18T12:00:00Z"`
+	UpdatedAt        time.Time  `json:"updated_at" example:"2026-10-18T12:00:00Z"`
+}
+
+// CreateResponse includes the key itself, which is only shown once
+type CreateResponse struct {
+	Response
+	Key string `json:"key" example:"sk_3f9a1c2b7d4e_Vh2bS0m3kQ9xY7pL1cN5rT8wZ4aD6fG2hJ0kM3nP5qR"`
+}
+
+type ListResponse struct {
+	APIKeys []Response `json:"api_keys"`
+}
+
+type ServiceAccountResponse struct {
+	ID          uint      `json:"id" example:"1"`
+	Name        string    `json:"name" example:"billing-batch"`
+	Description string    `json:"description" example:"Nightly billing export"`
+	CreatedByID *uint     `json:"created_by_id" example:"1"`
+	CreatedAt   time.Time `json:"created_at" example:"2026-10-18T12:00:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2026-10-18T12:00:00Z"`
+}
+
+type ServiceAccountListResponse struct {
+	ServiceAccounts []ServiceAccountResponse `json:"service_accounts"`
+}
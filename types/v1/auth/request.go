@@ -0,0 +1,42 @@
+package auth
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required" example:"johndoe"`
+	Password string `json:"password" binding:"required" example:"secretpassword123"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" example:"kq3sXb0fJ2m1..."`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" example:"kq3sXb0fJ2m1..."`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"john@example.com"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required" example:"Zm9yZ290LXBhc3N3b3Jk..."`
+	Password string `json:"password" binding:"required" example:"newpassword123"`
+}
+
+type MFAEnrollRequest struct {
+	Password string `json:"password" binding:"required" example:"secretpassword123"`
+}
+
+type MFAConfirmRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required" example:"secretpassword123"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	// Code is a TOTP code or one of the recovery codes
+	Code string `json:"code" binding:"required" example:"123456"`
+}
@@ -0,0 +1,35 @@
+package auth
+
+import "time"
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `json:"refresh_token" example:"kq3sXb0fJ2m1..."`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+}
+
+type VerifyEmailResponse struct {
+	Email           string    `json:"email" example:"john@example.com"`
+	EmailVerifiedAt time.Time `json:"email_verified_at" example:"2024-10-26T12:34:56Z"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required" example:"true"`
+	MFAToken    string `json:"mfa_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	ExpiresIn   int64  `json:"expires_in" example:"300"`
+}
+
+type MFAStatusResponse struct {
+	Enabled                bool  `json:"enabled" example:"true"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining" example:"10"`
+}
+
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/golang-gin-starter-kit:john@example.com?algorithm=SHA1&digits=6&issuer=golang-gin-starter-kit&period=30&secret=JBSWY3DPEHPK3PXP"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k3m7q-2xw9d,p4t6z-8nh2c"`
+}
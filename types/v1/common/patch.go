@@ -0,0 +1,22 @@
+package common
+
+import "encoding/json"
+
+// PatchField is a member of a JSON Merge Patch (RFC 7396) document. It
+// tells apart a member that is absent (Set is false), explicitly null
+// (Null is true) and present with a value.
+type PatchField[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON is only called for members present in the document
+func (f *PatchField[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+	if string(data) == "null" {
+		f.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &f.Value)
+}
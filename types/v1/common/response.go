@@ -1,8 +1,23 @@
 package common
 
-type ErrorResponse struct {
-	Error   string `json:"error" example:"Invalid request parameters"`
-	Message string `json:"message,omitempty" example:"Email is already taken"`
+// Problem is an RFC 7807 problem details document, served as
+// application/problem+json for every failed request
+type Problem struct {
+	Type      string       `json:"type" example:"about:blank"`
+	Title     string       `json:"title" example:"Conflict"`
+	Status    int          `json:"status" example:"409"`
+	Detail    string       `json:"detail,omitempty" example:"Email is already taken"`
+	Instance  string       `json:"instance,omitempty" example:"/api/v1/users"`
+	Code      string       `json:"code" example:"email_taken"`
+	RequestID string       `json:"request_id,omitempty" example:"4f1c2a9b8e7d6c5b4a39281706f5e4d3"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError explains why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Code    string `json:"code" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
 }
 
 type PaginationQuery struct {
@@ -6,8 +6,28 @@ type CreateRequest struct {
 	Password string `json:"password" binding:"required" example:"secretpassword123"`
 }
 
-type UpdateRequest struct {
-	Username string `json:"username" example:"johndoe"`
-	Email    string `json:"email" binding:"email" example:"john@example.com"`
-	Password string `json:"password" example:"newpassword123"`
+type ReplaceRequest struct {
+	Username        string `json:"username" binding:"required" example:"johndoe"`
+	Email           string `json:"email" binding:"required,email" example:"john@example.com"`
+	Password        string `json:"password,omitempty" example:"newpassword123"`
+	CurrentPassword string `json:"current_password,omitempty" example:"secretpassword123"`
+}
+
+type PatchRequest struct {
+	Username        string `json:"username,omitempty" example:"johndoe"`
+	Email           string `json:"email,omitempty" example:"john@example.com"`
+	Password        string `json:"password,omitempty" example:"newpassword123"`
+	CurrentPassword string `json:"current_password,omitempty" example:"secretpassword123"`
+}
+
+type ListQuery struct {
+	Page          int    `form:"page,default=1" example:"1"`
+	PerPage       int    `form:"per_page,default=10" example:"10"`
+	Cursor        string `form:"cursor" example:"eyJ0IjoiMjAyNC0xMC0yNlQxMjozNDo1NloiLCJpZCI6NDJ9"`
+	IncludeTotal  bool   `form:"include_total" example:"true"`
+	Q             string `form:"q" example:"john"`
+	Email         string `form:"email" example:"john@example.com"`
+	CreatedAfter  string `form:"created_after" example:"2024-10-01T00:00:00Z"`
+	CreatedBefore string `form:"created_before" example:"2024-11-01"`
+	Sort          string `form:"sort" example:"-created_at,username"`
 }
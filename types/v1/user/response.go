@@ -1,18 +1,42 @@
 package user
 
-import "time"
+import (
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/types/v1/common"
+)
 
 type Response struct {
-	ID        uint      `json:"id" example:"1"`
-	Username  string    `json:"username" example:"johndoe"`
-	Email     string    `json:"email" example:"john@example.com"`
-	CreatedAt time.Time `json:"created_at" example:"2024-10-26T12:34:56Z"`
-	UpdatedAt time.Time `json:"updated_at" example:"2024-10-26T12:34:56Z"`
+	ID              uint       `json:"id" example:"1"`
+	Username        string     `json:"username" example:"johndoe"`
+	Email           string     `json:"email" example:"john@example.com"`
+	CreatedAt       time.Time  `json:"created_at" example:"2024-10-26T12:34:56Z"`
+	UpdatedAt       time.Time  `json:"updated_at" example:"2024-10-26T12:34:56Z"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" example:"2024-11-02T08:00:00Z"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at" example:"2024-10-26T12:40:00Z"`
 }
 
 type ListResponse struct {
 	Users      []Response `json:"users"`
-	TotalCount int64      `json:"total_count" example:"100"`
-	Page       int        `json:"page" example:"1"`
+	TotalCount *int64     `json:"total_count,omitempty" example:"100"`
+	Page       int        `json:"page,omitempty" example:"1"`
 	PerPage    int        `json:"per_page" example:"10"`
+	NextCursor string     `json:"next_cursor,omitempty" example:"eyJ0IjoiMjAyNC0xMC0yNlQxMjozNDo1NloiLCJpZCI6NDJ9"`
+}
+
+type ImportRow struct {
+	Row    int                 `json:"row" example:"1"`
+	Status string              `json:"status" example:"failed" enums:"created,failed"`
+	ID     uint                `json:"id,omitempty" example:"42"`
+	Code   string              `json:"code,omitempty" example:"email_taken"`
+	Detail string              `json:"detail,omitempty" example:"Email is already taken"`
+	Errors []common.FieldError `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	Total   int         `json:"total" example:"3"`
+	Created int         `json:"created" example:"2"`
+	Failed  int         `json:"failed" example:"1"`
+	Rows    []ImportRow `json:"rows"`
+	Error   string      `json:"error,omitempty" example:"Upload exceeds the 32 MiB import limit"`
 }
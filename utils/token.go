@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/canhbk/golang-gin-starter-kit/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Purposes separate the signing keys of special-purpose tokens from the one
+// of access tokens, so that no kind of token passes as another
+const (
+	emailVerificationPurpose = "email-verification"
+	mfaChallengePurpose      = "mfa-challenge"
+)
+
+// AccessClaims are the claims carried by a signed access token
+type AccessClaims struct {
+	UserID uint `json:"uid"`
+	jwt.RegisteredClaims
+}
+
+// GenerateAccessToken signs a short-lived access token for the given user
+func GenerateAccessToken(userID uint) (string, error) {
+	now := time.Now()
+
+	claims := AccessClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    config.JWT.Issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.JWT.AccessTokenTTL)),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.JWT.Secret))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ParseAccessToken verifies the signature and expiry of an access token
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(config.JWT.Secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(config.JWT.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// GenerateRefreshToken returns an opaque random token and the hash to persist
+func GenerateRefreshToken() (string, string, error) {
+	return GenerateOpaqueToken()
+}
+
+// GenerateOpaqueToken returns 256 random bits, URL-safe encoded, and the
+// hash to persist in place of the token
+func GenerateOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of an opaque token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// EmailVerificationClaims are the claims carried by an email verification
+// link. The address is included so that the link stops working once the
+// user changes their email.
+type EmailVerificationClaims struct {
+	UserID uint   `json:"uid"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// GenerateEmailVerificationToken signs a token confirming that the user
+// owns email, valid for ttl
+func GenerateEmailVerificationToken(userID uint, email string, ttl time.Duration) (string, error) {
+	claims := EmailVerificationClaims{
+		UserID:           userID,
+		Email:            email,
+		RegisteredClaims: purposeClaims(userID, ttl),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(purposeKey(emailVerificationPurpose))
+}
+
+// ParseEmailVerificationToken verifies the signature and expiry of an email
+// verification token
+func ParseEmailVerificationToken(tokenString string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+	if err := parsePurposeToken(tokenString, emailVerificationPurpose, claims); err != nil ||
+		claims.UserID == 0 || claims.Email == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// MFAChallengeClaims are the claims of the token handed out after a correct
+// password when the user still has to present a second factor
+type MFAChallengeClaims struct {
+	UserID uint `json:"uid"`
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAChallengeToken signs a token proving that the user passed the
+// password step of a login, valid for ttl. id identifies the challenge, so
+// that it can be used up.
+func GenerateMFAChallengeToken(userID uint, id string, ttl time.Duration) (string, error) {
+	claims := MFAChallengeClaims{
+		UserID:           userID,
+		RegisteredClaims: purposeClaims(userID, ttl),
+	}
+	claims.ID = id
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(purposeKey(mfaChallengePurpose))
+}
+
+// ParseMFAChallengeToken verifies the signature and expiry of an MFA
+// challenge token
+func ParseMFAChallengeToken(tokenString string) (*MFAChallengeClaims, error) {
+	claims := &MFAChallengeClaims{}
+	if err := parsePurposeToken(tokenString, mfaChallengePurpose, claims); err != nil || claims.UserID == 0 || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func purposeClaims(userID uint, ttl time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    config.JWT.Issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+}
+
+func parsePurposeToken(tokenString, purpose string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return purposeKey(purpose), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(config.JWT.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+// purposeKey derives a signing key for one kind of token from the JWT secret
+func purposeKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(config.JWT.Secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}